@@ -144,10 +144,10 @@ func (ce *Call_expression) String() string {
 	arguments := []string{}
 
 	for _, p := range ce.Arguments {
-		arguments = append(arguments, p.String())
+		arguments = append(arguments, string_of(p))
 	}
 
-	out.WriteString(ce.Function.String())
+	out.WriteString(string_of(ce.Function))
 	out.WriteString("(")
 	out.WriteString(strings.Join(arguments, ", "))
 	out.WriteString(")")
@@ -159,13 +159,13 @@ func (fl *Function_literal) String() string {
 
 	params := []string{}
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		params = append(params, string_of(p))
 	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
+	out.WriteString(string_of(fl.Body))
 
 	return out.String()
 }
@@ -173,22 +173,25 @@ func (fl *Function_literal) String() string {
 func (bs *Block_statement) String() string {
 	var out bytes.Buffer
 
-	for _, s := range bs.Statements {
-		out.WriteString(s.String())
+	out.WriteString("{ ")
+	write_statements(&out, bs.Statements)
+	if len(bs.Statements) > 0 {
+		out.WriteString(" ")
 	}
+	out.WriteString("}")
 	return out.String()
 }
 
 func (ie *If_expression) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("if")
-	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
-	out.WriteString(ie.Consequence.String())
+	out.WriteString("if (")
+	out.WriteString(string_of(ie.Condition))
+	out.WriteString(") ")
+	out.WriteString(string_of(ie.Consequence))
 
 	if ie.Alternative != nil {
-		out.WriteString("else")
+		out.WriteString(" else ")
 		out.WriteString(ie.Alternative.String())
 	}
 
@@ -199,9 +202,9 @@ func (ie Infix_expression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
-	out.WriteString(ie.Left.String())
+	out.WriteString(string_of(ie.Left))
 	out.WriteString(" " + ie.Operator + " ")
-	out.WriteString(ie.Right.String())
+	out.WriteString(string_of(ie.Right))
 	out.WriteString(")")
 
 	return out.String()
@@ -214,7 +217,7 @@ func (pe *Prefix_expression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
-	out.WriteString(pe.Right.String())
+	out.WriteString(string_of(pe.Right))
 	out.WriteString(")")
 
 	return out.String()
@@ -223,23 +226,30 @@ func (pe *Prefix_expression) String() string {
 func (p *Program) String() string {
 	var out bytes.Buffer
 
-	for _, s := range p.Statements {
-		out.WriteString(s.String())
+	write_statements(&out, p.Statements)
+	return out.String()
+}
+
+// write_statements separates expression statements from whatever follows
+// them, so that "a; (b)" is not printed as the call "a(b)".
+func write_statements(out *bytes.Buffer, statements []Statement) {
+	for i, s := range statements {
+		out.WriteString(string_of(s))
 
+		if _, ok := s.(*Expression_statement); ok && i < len(statements)-1 {
+			out.WriteString(";")
+		}
 	}
-	return out.String()
 }
 
 func (ls *Let_statement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(string_of(ls.Name))
 	out.WriteString(" = ")
 
-	if ls.Value != nil {
-		out.WriteString(ls.Value.String())
-	}
+	out.WriteString(string_of(ls.Value))
 
 	out.WriteString(";")
 	return out.String()
@@ -249,9 +259,7 @@ func (rs *Return_statement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(rs.TokenLiteral() + " ")
-	if rs.Return_value != nil {
-		out.WriteString(rs.Return_value.String())
-	}
+	out.WriteString(string_of(rs.Return_value))
 
 	out.WriteString(";")
 
@@ -259,10 +267,16 @@ func (rs *Return_statement) String() string {
 }
 
 func (es *Expression_statement) String() string {
-	if es.Expression != nil {
-		return es.Expression.String()
-	}
-	return ""
+	return string_of(es.Expression)
 }
 
 func (i *Identifier) String() string { return i.Value }
+
+// string_of prints a missing child as nothing, so that incomplete trees
+// built by hand or by parser extensions can still be printed.
+func string_of(node Node) string {
+	if Is_nil(node) {
+		return ""
+	}
+	return node.String()
+}
@@ -25,3 +25,24 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong, got=%q", program.String())
 	}
 }
+
+func TestStringIncomplete(t *testing.T) {
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{&Infix_expression{Operator: "+", Left: &Identifier{Value: "a"}}, "(a + )"},
+		{&Prefix_expression{Operator: "-"}, "(-)"},
+		{&Call_expression{Arguments: []Expression{nil, &Identifier{Value: "x"}}}, "(, x)"},
+		{&If_expression{}, "if () "},
+		{&Function_literal{Token: token.Token{Literal: "fn"}}, "fn() "},
+		{&Let_statement{Token: token.Token{Literal: "let"}}, "let  = ;"},
+		{&Program{Statements: []Statement{nil, &Expression_statement{Expression: &Identifier{Value: "a"}}}}, "a"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, tt.node.String())
+		}
+	}
+}
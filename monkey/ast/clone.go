@@ -0,0 +1,104 @@
+package ast
+
+// Clone returns a deep copy of node. Tokens are copied by value, nil
+// children stay nil.
+func Clone[T Node](node T) T {
+	if Is_nil(node) {
+		return node
+	}
+	return clone_node(node).(T)
+}
+
+func clone_node(node Node) Node {
+	if Is_nil(node) {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: clone_statements(n.Statements)}
+	case *Let_statement:
+		return &Let_statement{Token: n.Token, Name: clone_identifier(n.Name), Value: clone_expression(n.Value)}
+	case *Return_statement:
+		return &Return_statement{Token: n.Token, Return_value: clone_expression(n.Return_value)}
+	case *Expression_statement:
+		return &Expression_statement{Token: n.Token, Expression: clone_expression(n.Expression)}
+	case *Block_statement:
+		return clone_block(n)
+	case *Identifier:
+		return clone_identifier(n)
+	case *Integer_literal:
+		return &Integer_literal{Token: n.Token, Value: n.Value}
+	case *Boolean:
+		return &Boolean{Token: n.Token, Value: n.Value}
+	case *Prefix_expression:
+		return &Prefix_expression{Token: n.Token, Operator: n.Operator, Right: clone_expression(n.Right)}
+	case *Infix_expression:
+		return &Infix_expression{
+			Token:    n.Token,
+			Operator: n.Operator,
+			Left:     clone_expression(n.Left),
+			Right:    clone_expression(n.Right),
+		}
+	case *If_expression:
+		return &If_expression{
+			Token:       n.Token,
+			Condition:   clone_expression(n.Condition),
+			Consequence: clone_block(n.Consequence),
+			Alternative: clone_block(n.Alternative),
+		}
+	case *Function_literal:
+		var params []*Identifier
+		if n.Parameters != nil {
+			params = make([]*Identifier, len(n.Parameters))
+			for i, p := range n.Parameters {
+				params[i] = clone_identifier(p)
+			}
+		}
+		return &Function_literal{Token: n.Token, Parameters: params, Body: clone_block(n.Body)}
+	case *Call_expression:
+		var args []Expression
+		if n.Arguments != nil {
+			args = make([]Expression, len(n.Arguments))
+			for i, a := range n.Arguments {
+				args[i] = clone_expression(a)
+			}
+		}
+		return &Call_expression{Token: n.Token, Function: clone_expression(n.Function), Arguments: args}
+	}
+	return node
+}
+
+func clone_statements(statements []Statement) []Statement {
+	if statements == nil {
+		return nil
+	}
+	out := make([]Statement, len(statements))
+	for i, s := range statements {
+		if c := clone_node(s); c != nil {
+			out[i] = c.(Statement)
+		}
+	}
+	return out
+}
+
+func clone_expression(expr Expression) Expression {
+	if c := clone_node(expr); c != nil {
+		return c.(Expression)
+	}
+	return nil
+}
+
+func clone_identifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return &Identifier{Token: ident.Token, Value: ident.Value}
+}
+
+func clone_block(block *Block_statement) *Block_statement {
+	if block == nil {
+		return nil
+	}
+	return &Block_statement{Token: block.Token, Statements: clone_statements(block.Statements)}
+}
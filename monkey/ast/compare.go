@@ -0,0 +1,270 @@
+package ast
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"monkey/token"
+)
+
+type equal_config struct {
+	ignore_tokens    bool
+	ignore_positions bool
+}
+
+type Equal_option func(*equal_config)
+
+// Ignore_tokens makes Equal compare only the structure and values of the
+// nodes, not the tokens they were parsed from.
+func Ignore_tokens() Equal_option {
+	return func(c *equal_config) { c.ignore_tokens = true }
+}
+
+// Ignore_positions makes Equal compare tokens by type and literal only, so
+// trees parsed from differently laid out or differently located source
+// can still be equal.
+func Ignore_positions() Equal_option {
+	return func(c *equal_config) { c.ignore_positions = true }
+}
+
+// Equal reports whether a and b are structurally identical trees.
+func Equal(a, b Node, opts ...Equal_option) bool {
+	cfg := equal_config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg.equal(a, b)
+}
+
+func (c *equal_config) equal(a, b Node) bool {
+	if Is_nil(a) || Is_nil(b) {
+		return Is_nil(a) && Is_nil(b)
+	}
+
+	switch x := a.(type) {
+	case *Program:
+		y, ok := b.(*Program)
+		return ok && c.equal_statements(x.Statements, y.Statements)
+	case *Let_statement:
+		y, ok := b.(*Let_statement)
+		return ok && c.equal_token(x.Token, y.Token) &&
+			c.equal(x.Name, y.Name) && c.equal(x.Value, y.Value)
+	case *Return_statement:
+		y, ok := b.(*Return_statement)
+		return ok && c.equal_token(x.Token, y.Token) && c.equal(x.Return_value, y.Return_value)
+	case *Expression_statement:
+		y, ok := b.(*Expression_statement)
+		return ok && c.equal_token(x.Token, y.Token) && c.equal(x.Expression, y.Expression)
+	case *Block_statement:
+		y, ok := b.(*Block_statement)
+		return ok && c.equal_token(x.Token, y.Token) && c.equal_statements(x.Statements, y.Statements)
+	case *Identifier:
+		y, ok := b.(*Identifier)
+		return ok && c.equal_token(x.Token, y.Token) && x.Value == y.Value
+	case *Integer_literal:
+		y, ok := b.(*Integer_literal)
+		return ok && c.equal_token(x.Token, y.Token) && x.Value == y.Value
+	case *Boolean:
+		y, ok := b.(*Boolean)
+		return ok && c.equal_token(x.Token, y.Token) && x.Value == y.Value
+	case *Prefix_expression:
+		y, ok := b.(*Prefix_expression)
+		return ok && c.equal_token(x.Token, y.Token) && x.Operator == y.Operator &&
+			c.equal(x.Right, y.Right)
+	case *Infix_expression:
+		y, ok := b.(*Infix_expression)
+		return ok && c.equal_token(x.Token, y.Token) && x.Operator == y.Operator &&
+			c.equal(x.Left, y.Left) && c.equal(x.Right, y.Right)
+	case *If_expression:
+		y, ok := b.(*If_expression)
+		return ok && c.equal_token(x.Token, y.Token) && c.equal(x.Condition, y.Condition) &&
+			c.equal(x.Consequence, y.Consequence) && c.equal(x.Alternative, y.Alternative)
+	case *Function_literal:
+		y, ok := b.(*Function_literal)
+		if !ok || !c.equal_token(x.Token, y.Token) || len(x.Parameters) != len(y.Parameters) {
+			return false
+		}
+		for i := range x.Parameters {
+			if !c.equal(x.Parameters[i], y.Parameters[i]) {
+				return false
+			}
+		}
+		return c.equal(x.Body, y.Body)
+	case *Call_expression:
+		y, ok := b.(*Call_expression)
+		if !ok || !c.equal_token(x.Token, y.Token) || len(x.Arguments) != len(y.Arguments) {
+			return false
+		}
+		for i := range x.Arguments {
+			if !c.equal(x.Arguments[i], y.Arguments[i]) {
+				return false
+			}
+		}
+		return c.equal(x.Function, y.Function)
+	}
+	return false
+}
+
+func (c *equal_config) equal_statements(a, b []Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !c.equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *equal_config) equal_token(a, b token.Token) bool {
+	if c.ignore_tokens {
+		return true
+	}
+	if c.ignore_positions {
+		return a.Type == b.Type && a.Literal == b.Literal
+	}
+	return a == b
+}
+
+// Hash returns a structural hash of node. Tokens are not hashed, so nodes
+// that are Equal with Ignore_tokens have the same hash.
+func Hash(node Node) uint64 {
+	h := fnv.New64a()
+	hash_node(h, node)
+	return h.Sum64()
+}
+
+const (
+	hash_nil byte = iota
+	hash_program
+	hash_let
+	hash_return
+	hash_expression_statement
+	hash_block
+	hash_identifier
+	hash_integer
+	hash_boolean
+	hash_prefix
+	hash_infix
+	hash_if
+	hash_function
+	hash_call
+)
+
+func hash_node(h hash.Hash64, node Node) {
+	if Is_nil(node) {
+		h.Write([]byte{hash_nil})
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		h.Write([]byte{hash_program})
+		hash_statements(h, n.Statements)
+	case *Let_statement:
+		h.Write([]byte{hash_let})
+		hash_node(h, n.Name)
+		hash_node(h, n.Value)
+	case *Return_statement:
+		h.Write([]byte{hash_return})
+		hash_node(h, n.Return_value)
+	case *Expression_statement:
+		h.Write([]byte{hash_expression_statement})
+		hash_node(h, n.Expression)
+	case *Block_statement:
+		h.Write([]byte{hash_block})
+		hash_statements(h, n.Statements)
+	case *Identifier:
+		h.Write([]byte{hash_identifier})
+		hash_string(h, n.Value)
+	case *Integer_literal:
+		h.Write([]byte{hash_integer})
+		h.Write(binary.LittleEndian.AppendUint64(nil, uint64(n.Value)))
+	case *Boolean:
+		if n.Value {
+			h.Write([]byte{hash_boolean, 1})
+		} else {
+			h.Write([]byte{hash_boolean, 0})
+		}
+	case *Prefix_expression:
+		h.Write([]byte{hash_prefix})
+		hash_string(h, n.Operator)
+		hash_node(h, n.Right)
+	case *Infix_expression:
+		h.Write([]byte{hash_infix})
+		hash_string(h, n.Operator)
+		hash_node(h, n.Left)
+		hash_node(h, n.Right)
+	case *If_expression:
+		h.Write([]byte{hash_if})
+		hash_node(h, n.Condition)
+		hash_node(h, n.Consequence)
+		hash_node(h, n.Alternative)
+	case *Function_literal:
+		h.Write([]byte{hash_function})
+		hash_length(h, len(n.Parameters))
+		for _, p := range n.Parameters {
+			hash_node(h, p)
+		}
+		hash_node(h, n.Body)
+	case *Call_expression:
+		h.Write([]byte{hash_call})
+		hash_node(h, n.Function)
+		hash_length(h, len(n.Arguments))
+		for _, a := range n.Arguments {
+			hash_node(h, a)
+		}
+	}
+}
+
+func hash_statements(h hash.Hash64, statements []Statement) {
+	hash_length(h, len(statements))
+	for _, s := range statements {
+		hash_node(h, s)
+	}
+}
+
+func hash_string(h hash.Hash64, s string) {
+	hash_length(h, len(s))
+	h.Write([]byte(s))
+}
+
+func hash_length(h hash.Hash64, n int) {
+	h.Write(binary.LittleEndian.AppendUint64(nil, uint64(n)))
+}
+
+// Is_nil reports whether node is nil or a nil pointer to one of the node types.
+func Is_nil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *Program:
+		return n == nil
+	case *Let_statement:
+		return n == nil
+	case *Return_statement:
+		return n == nil
+	case *Expression_statement:
+		return n == nil
+	case *Block_statement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	case *Integer_literal:
+		return n == nil
+	case *Boolean:
+		return n == nil
+	case *Prefix_expression:
+		return n == nil
+	case *Infix_expression:
+		return n == nil
+	case *If_expression:
+		return n == nil
+	case *Function_literal:
+		return n == nil
+	case *Call_expression:
+		return n == nil
+	}
+	return false
+}
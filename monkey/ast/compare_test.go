@@ -0,0 +1,138 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"testing"
+)
+
+var compare_inputs = []string{
+	"let x = 5;",
+	"return a + b * c;",
+	"-a * !b",
+	"if (x < y) { x } else { y }",
+	"if (x < y) { x }",
+	"fn(x, y) { x + y; }",
+	"fn() { }",
+	"add(1, 2 * 3, fn(a) { a })",
+	"true == false",
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.Parse_program()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestEqual(t *testing.T) {
+	for i, a := range compare_inputs {
+		for j, b := range compare_inputs {
+			got := ast.Equal(parse(t, a), parse(t, b))
+			if got != (i == j) {
+				t.Errorf("Equal(%q, %q) = %t", a, b, got)
+			}
+		}
+	}
+}
+
+func TestEqualIgnoreTokens(t *testing.T) {
+	a := parse(t, "let x = 5;")
+	b := parse(t, "let x = 5;")
+
+	value := b.Statements[0].(*ast.Let_statement).Value.(*ast.Integer_literal)
+	value.Token = token.Token{Type: token.INT, Literal: "0005"}
+
+	if ast.Equal(a, b) {
+		t.Errorf("Equal ignored a differing token")
+	}
+	if !ast.Equal(a, b, ast.Ignore_tokens()) {
+		t.Errorf("Equal with Ignore_tokens compared tokens")
+	}
+	if ast.Hash(a) != ast.Hash(b) {
+		t.Errorf("Hash differs for nodes equal up to tokens")
+	}
+}
+
+func TestEqualIgnorePositions(t *testing.T) {
+	a := parse(t, "let x = 5;")
+	b := parse(t, "\n\nlet   x =\n5;")
+
+	if ast.Equal(a, b) {
+		t.Errorf("Equal ignored differing positions")
+	}
+	if !ast.Equal(a, b, ast.Ignore_positions()) {
+		t.Errorf("Equal with Ignore_positions compared positions")
+	}
+	if ast.Equal(a, parse(t, "let x = 6;"), ast.Ignore_positions()) {
+		t.Errorf("Equal with Ignore_positions ignored values")
+	}
+}
+
+func TestEqualNil(t *testing.T) {
+	var block *ast.Block_statement
+
+	if !ast.Equal(nil, block) {
+		t.Errorf("nil and typed nil not equal")
+	}
+	if ast.Equal(block, &ast.Block_statement{}) {
+		t.Errorf("nil block equal to empty block")
+	}
+}
+
+func TestHash(t *testing.T) {
+	seen := map[uint64]string{}
+	for _, input := range compare_inputs {
+		h := ast.Hash(parse(t, input))
+		if other, ok := seen[h]; ok {
+			t.Errorf("Hash collision between %q and %q", input, other)
+		}
+		seen[h] = input
+
+		if ast.Hash(parse(t, input)) != h {
+			t.Errorf("Hash(%q) is not deterministic", input)
+		}
+	}
+
+	if ast.Hash(parse(t, "a + b")) == ast.Hash(parse(t, "b + a")) {
+		t.Errorf("Hash ignores operand order")
+	}
+}
+
+func TestClone(t *testing.T) {
+	for _, input := range compare_inputs {
+		program := parse(t, input)
+		clone := ast.Clone(program)
+
+		if clone == program {
+			t.Fatalf("Clone(%q) returned the same pointer", input)
+		}
+		if !ast.Equal(program, clone) {
+			t.Errorf("Clone(%q) not equal to original. got=%q", input, clone.String())
+		}
+	}
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	program := parse(t, "fn(x) { x + 1 }")
+	clone := ast.Clone(program)
+
+	fn := clone.Statements[0].(*ast.Expression_statement).Expression.(*ast.Function_literal)
+	fn.Parameters[0].Value = "y"
+	fn.Body.Statements[0].(*ast.Expression_statement).Expression.(*ast.Infix_expression).Operator = "-"
+
+	if ast.Equal(program, clone) {
+		t.Fatalf("modifying the clone changed the original: %q", program.String())
+	}
+
+	var expr ast.Expression
+	if ast.Clone(expr) != nil {
+		t.Errorf("Clone(nil) not nil")
+	}
+}
@@ -0,0 +1,172 @@
+package astdump
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/ast"
+	"strconv"
+	"strings"
+)
+
+type edge struct {
+	label string
+	node  ast.Node
+}
+
+// Sexpr renders node as an indented S-expression tree, one node per line.
+func Sexpr(node ast.Node) string {
+	var out bytes.Buffer
+	write_sexpr(&out, node, 0)
+	out.WriteString("\n")
+	return out.String()
+}
+
+// Dot renders node as a Graphviz digraph with one vertex per AST node and
+// edges labeled with the field that holds the child.
+func Dot(node ast.Node) string {
+	var out bytes.Buffer
+
+	out.WriteString("digraph ast {\n")
+	out.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	next := 0
+	var walk func(node ast.Node) int
+	walk = func(node ast.Node) int {
+		id := next
+		next++
+
+		fmt.Fprintf(&out, "\tn%d [label=%s];\n", id, dot_quote(dot_label(node)))
+		if ast.Is_nil(node) {
+			return id
+		}
+		for _, e := range children(node) {
+			child := walk(e.node)
+			fmt.Fprintf(&out, "\tn%d -> n%d [label=%s];\n", id, child, dot_quote(e.label))
+		}
+		return id
+	}
+	walk(node)
+
+	out.WriteString("}\n")
+	return out.String()
+}
+
+func write_sexpr(out *bytes.Buffer, node ast.Node, depth int) {
+	out.WriteString(strings.Repeat("  ", depth))
+
+	if ast.Is_nil(node) {
+		out.WriteString("nil")
+		return
+	}
+
+	out.WriteString("(")
+	out.WriteString(sexpr_head(node))
+
+	for _, e := range children(node) {
+		out.WriteString("\n")
+		write_sexpr(out, e.node, depth+1)
+	}
+	out.WriteString(")")
+}
+
+func sexpr_head(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.Program:
+		return "program"
+	case *ast.Let_statement:
+		return "let"
+	case *ast.Return_statement:
+		return "return"
+	case *ast.Expression_statement:
+		return "expr"
+	case *ast.Block_statement:
+		return "block"
+	case *ast.Identifier:
+		return "ident " + n.Value
+	case *ast.Integer_literal:
+		return "int " + strconv.FormatInt(n.Value, 10)
+	case *ast.Boolean:
+		return "bool " + strconv.FormatBool(n.Value)
+	case *ast.Prefix_expression:
+		return "prefix " + n.Operator
+	case *ast.Infix_expression:
+		return "infix " + n.Operator
+	case *ast.If_expression:
+		return "if"
+	case *ast.Function_literal:
+		return "fn"
+	case *ast.Call_expression:
+		return "call"
+	default:
+		return fmt.Sprintf("%T", node)
+	}
+}
+
+func dot_label(node ast.Node) string {
+	if ast.Is_nil(node) {
+		return "nil"
+	}
+
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	switch n := node.(type) {
+	case *ast.Identifier:
+		return name + "\n" + n.Value
+	case *ast.Integer_literal:
+		return name + "\n" + strconv.FormatInt(n.Value, 10)
+	case *ast.Boolean:
+		return name + "\n" + strconv.FormatBool(n.Value)
+	case *ast.Prefix_expression:
+		return name + "\n" + n.Operator
+	case *ast.Infix_expression:
+		return name + "\n" + n.Operator
+	}
+	return name
+}
+
+func dot_quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func children(node ast.Node) []edge {
+	var edges []edge
+
+	switch n := node.(type) {
+	case *ast.Program:
+		for i, s := range n.Statements {
+			edges = append(edges, edge{fmt.Sprintf("Statements[%d]", i), s})
+		}
+	case *ast.Let_statement:
+		edges = append(edges, edge{"Name", n.Name}, edge{"Value", n.Value})
+	case *ast.Return_statement:
+		edges = append(edges, edge{"Return_value", n.Return_value})
+	case *ast.Expression_statement:
+		edges = append(edges, edge{"Expression", n.Expression})
+	case *ast.Block_statement:
+		for i, s := range n.Statements {
+			edges = append(edges, edge{fmt.Sprintf("Statements[%d]", i), s})
+		}
+	case *ast.Prefix_expression:
+		edges = append(edges, edge{"Right", n.Right})
+	case *ast.Infix_expression:
+		edges = append(edges, edge{"Left", n.Left}, edge{"Right", n.Right})
+	case *ast.If_expression:
+		edges = append(edges, edge{"Condition", n.Condition}, edge{"Consequence", n.Consequence})
+		if n.Alternative != nil {
+			edges = append(edges, edge{"Alternative", n.Alternative})
+		}
+	case *ast.Function_literal:
+		for i, p := range n.Parameters {
+			edges = append(edges, edge{fmt.Sprintf("Parameters[%d]", i), p})
+		}
+		edges = append(edges, edge{"Body", n.Body})
+	case *ast.Call_expression:
+		edges = append(edges, edge{"Function", n.Function})
+		for i, a := range n.Arguments {
+			edges = append(edges, edge{fmt.Sprintf("Arguments[%d]", i), a})
+		}
+	}
+	return edges
+}
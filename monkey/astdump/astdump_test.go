@@ -0,0 +1,125 @@
+package astdump
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestSexpr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x = 1 + y;",
+			`(program
+  (let
+    (ident x)
+    (infix +
+      (int 1)
+      (ident y))))
+`,
+		},
+		{
+			"-a * b",
+			`(program
+  (expr
+    (infix *
+      (prefix -
+        (ident a))
+      (ident b))))
+`,
+		},
+		{
+			"if (x) { return true; } else { add(1, 2) }",
+			`(program
+  (expr
+    (if
+      (ident x)
+      (block
+        (return
+          (bool true)))
+      (block
+        (expr
+          (call
+            (ident add)
+            (int 1)
+            (int 2)))))))
+`,
+		},
+		{
+			"fn(a, b) { }",
+			`(program
+  (expr
+    (fn
+      (ident a)
+      (ident b)
+      (block))))
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.Parse_program()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		actual := Sexpr(program)
+		if actual != tt.expected {
+			t.Errorf("Sexpr(%q) wrong.\nexpected=\n%s\ngot=\n%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestDot(t *testing.T) {
+	p := parser.New(lexer.New("if (a < b) { a } else { b }"))
+	program := p.Parse_program()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	actual := Dot(program)
+
+	expected := []string{
+		"digraph ast {",
+		`n0 [label="Program"];`,
+		`n1 [label="Expression_statement"];`,
+		`n0 -> n1 [label="Statements[0]"];`,
+		`n3 [label="Infix_expression\n<"];`,
+		`n2 -> n3 [label="Condition"];`,
+		`n2 -> n6 [label="Consequence"];`,
+		`n2 -> n9 [label="Alternative"];`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(actual, want) {
+			t.Errorf("Dot output missing %q. got=\n%s", want, actual)
+		}
+	}
+	if !strings.HasSuffix(actual, "}\n") {
+		t.Errorf("Dot output not terminated. got=\n%s", actual)
+	}
+}
+
+func TestDumpNilChildren(t *testing.T) {
+	// The parser drops statements it could not complete, but hand-built
+	// trees may still lack children.
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.Let_statement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
+		},
+	}}
+
+	if strings.Count(Sexpr(program), "nil") == 0 {
+		t.Errorf("expected nil child in Sexpr. got=\n%s", Sexpr(program))
+	}
+	if !strings.Contains(Dot(program), `[label="nil"]`) {
+		t.Errorf("expected nil vertex in Dot. got=\n%s", Dot(program))
+	}
+}
@@ -0,0 +1,3 @@
+module monkey/astdump
+
+go 1.24.1
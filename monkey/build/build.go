@@ -0,0 +1,180 @@
+// Package build constructs ASTs. The constructors fill in the same tokens
+// the parser would produce for the String() form of the node, so a built
+// tree prints as valid Monkey source and parsing that source yields an
+// equal tree. They panic on input that cannot be expressed in Monkey, such
+// as a keyword used as a name.
+package build
+
+import (
+	"fmt"
+	"math"
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+)
+
+var operators = map[string]token.TokenType{
+	"+":  token.PLUS,
+	"-":  token.MINUS,
+	"*":  token.ASTERISK,
+	"/":  token.SLASH,
+	"<":  token.LT,
+	">":  token.GT,
+	"==": token.EQ,
+	"!=": token.NOT_EQ,
+	"!":  token.BANG,
+}
+
+func Program(statements ...ast.Statement) *ast.Program {
+	return &ast.Program{Statements: statements_or_empty(statements)}
+}
+
+func Let(name string, value ast.Expression) *ast.Let_statement {
+	return &ast.Let_statement{
+		Token: token.Token{Type: token.LET, Literal: "let"},
+		Name:  Ident(name),
+		Value: value,
+	}
+}
+
+func Return(value ast.Expression) *ast.Return_statement {
+	return &ast.Return_statement{
+		Token:        token.Token{Type: token.RETURN, Literal: "return"},
+		Return_value: value,
+	}
+}
+
+func Expr(expr ast.Expression) *ast.Expression_statement {
+	return &ast.Expression_statement{Token: first_token(expr), Expression: expr}
+}
+
+func Block(statements ...ast.Statement) *ast.Block_statement {
+	return &ast.Block_statement{
+		Token:      token.Token{Type: token.LBRACE, Literal: "{"},
+		Statements: statements_or_empty(statements),
+	}
+}
+
+func Ident(name string) *ast.Identifier {
+	if !is_identifier(name) {
+		panic(fmt.Sprintf("build: %q is not a valid identifier", name))
+	}
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+// Int returns an integer literal, or the negation of one when value is
+// negative since Monkey has no negative literals.
+func Int(value int64) ast.Expression {
+	if value == math.MinInt64 {
+		return Infix(Int(-math.MaxInt64), "-", Int(1))
+	}
+	if value < 0 {
+		return Prefix("-", Int(-value))
+	}
+	literal := strconv.FormatInt(value, 10)
+	return &ast.Integer_literal{Token: token.Token{Type: token.INT, Literal: literal}, Value: value}
+}
+
+func Bool(value bool) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true}
+	}
+	return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}, Value: false}
+}
+
+func Prefix(operator string, right ast.Expression) *ast.Prefix_expression {
+	if operator != "-" && operator != "!" {
+		panic(fmt.Sprintf("build: %q is not a prefix operator", operator))
+	}
+	return &ast.Prefix_expression{Token: operator_token(operator), Operator: operator, Right: right}
+}
+
+func Infix(left ast.Expression, operator string, right ast.Expression) *ast.Infix_expression {
+	if operator == "!" {
+		panic(fmt.Sprintf("build: %q is not an infix operator", operator))
+	}
+	return &ast.Infix_expression{Token: operator_token(operator), Operator: operator, Left: left, Right: right}
+}
+
+// If builds an if expression; alternative may be nil.
+func If(condition ast.Expression, consequence, alternative *ast.Block_statement) *ast.If_expression {
+	return &ast.If_expression{
+		Token:       token.Token{Type: token.IF, Literal: "if"},
+		Condition:   condition,
+		Consequence: consequence,
+		Alternative: alternative,
+	}
+}
+
+func Fn(parameters []string, body ...ast.Statement) *ast.Function_literal {
+	params := []*ast.Identifier{}
+	for _, name := range parameters {
+		params = append(params, Ident(name))
+	}
+	return &ast.Function_literal{
+		Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+		Parameters: params,
+		Body:       Block(body...),
+	}
+}
+
+func Call(function ast.Expression, arguments ...ast.Expression) *ast.Call_expression {
+	if arguments == nil {
+		arguments = []ast.Expression{}
+	}
+	return &ast.Call_expression{
+		Token:     token.Token{Type: token.LPAREN, Literal: "("},
+		Function:  function,
+		Arguments: arguments,
+	}
+}
+
+func operator_token(operator string) token.Token {
+	t, ok := operators[operator]
+	if !ok {
+		panic(fmt.Sprintf("build: unknown operator %q", operator))
+	}
+	return token.Token{Type: t, Literal: operator}
+}
+
+// first_token returns the token the parser sees first when reading the
+// String() form of expr.
+func first_token(expr ast.Expression) token.Token {
+	switch e := expr.(type) {
+	case *ast.Prefix_expression, *ast.Infix_expression:
+		return token.Token{Type: token.LPAREN, Literal: "("}
+	case *ast.Call_expression:
+		return first_token(e.Function)
+	case *ast.Identifier:
+		return e.Token
+	case *ast.Integer_literal:
+		return e.Token
+	case *ast.Boolean:
+		return e.Token
+	case *ast.If_expression:
+		return e.Token
+	case *ast.Function_literal:
+		return e.Token
+	}
+	return token.Token{}
+}
+
+func statements_or_empty(statements []ast.Statement) []ast.Statement {
+	if statements == nil {
+		return []ast.Statement{}
+	}
+	return statements
+}
+
+func is_identifier(name string) bool {
+	if name == "" || token.Lookup_identifier(name) != token.IDENT {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package build
+
+import (
+	"math"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestBuildString(t *testing.T) {
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{Let("x", Infix(Int(1), "+", Ident("y"))), "let x = (1 + y);"},
+		{Return(Prefix("!", Bool(true))), "return (!true);"},
+		{Expr(Int(-5)), "(-5)"},
+		{Expr(Int(math.MinInt64)), "((-9223372036854775807) - 1)"},
+		{
+			Expr(If(Infix(Ident("a"), "<", Ident("b")), Block(Expr(Ident("a"))), Block(Expr(Ident("b"))))),
+			"if ((a < b)) { a } else { b }",
+		},
+		{Expr(Fn(nil)), "fn() { }"},
+		{
+			Let("add", Fn([]string{"a", "b"}, Return(Infix(Ident("a"), "+", Ident("b"))))),
+			"let add = fn(a, b) { return (a + b); };",
+		},
+		{Expr(Call(Ident("add"), Int(1), Call(Ident("f")))), "add(1, f())"},
+		{Program(Expr(Ident("a")), Expr(Prefix("-", Ident("b")))), "a;(-b)"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, tt.node.String())
+		}
+	}
+}
+
+func TestBuildRoundTrip(t *testing.T) {
+	programs := []*ast.Program{
+		Program(
+			Let("x", Int(5)),
+			Let("double", Fn([]string{"n"}, Expr(Infix(Ident("n"), "*", Int(2))))),
+			Expr(Call(Ident("double"), Ident("x"))),
+			Expr(Prefix("-", Ident("x"))),
+		),
+		Program(
+			Expr(If(Infix(Ident("x"), "==", Bool(false)), Block(Return(Int(-1))), nil)),
+			Expr(If(Ident("y"), Block(), Block(Let("z", Int(0)), Expr(Ident("z"))))),
+		),
+		Program(
+			Expr(Call(Fn([]string{"a"}, Expr(Ident("a"))), Int(math.MinInt64))),
+			Return(Infix(Infix(Int(1), "-", Int(2)), "!=", Prefix("!", Bool(true)))),
+		),
+	}
+
+	for _, program := range programs {
+		source := program.String()
+
+		p := parser.New(lexer.New(source))
+		parsed := p.Parse_program()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("built source %q does not parse: %v", source, p.Errors())
+		}
+
+		if !ast.Equal(program, parsed, ast.Ignore_positions()) {
+			t.Errorf("parsing %q produced a different tree: %q", source, parsed.String())
+		}
+	}
+}
+
+func TestBuildPanics(t *testing.T) {
+	tests := map[string]func(){
+		"keyword identifier": func() { Ident("let") },
+		"digit identifier":   func() { Ident("x1") },
+		"empty identifier":   func() { Ident("") },
+		"prefix operator":    func() { Prefix("+", Int(1)) },
+		"infix operator":     func() { Infix(Int(1), "%", Int(2)) },
+	}
+
+	for name, fn := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,3 @@
+module monkey/build
+
+go 1.24.1
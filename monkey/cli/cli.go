@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/astdump"
+	"monkey/diag"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/rpc"
+	"monkey/term"
+	"monkey/token"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// Exit statuses of Main.
+const (
+	EXIT_OK = 0
+	// EXIT_FAILURE means the input had errors or could not be read.
+	EXIT_FAILURE = 1
+	// EXIT_USAGE means the command line was wrong.
+	EXIT_USAGE = 2
+)
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(c *context, args []string) int
+}
+
+// commands is filled in by init, as help refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "<file>", "parse file and print the program, as the REPL does", (*context).run},
+		{"tokens", "[file...]", "print the tokens of each file", (*context).tokens},
+		{"ast", "[-format sexpr|dot] [file...]", "print the syntax tree of each file", (*context).ast},
+		{"check", "[file...]", "report the syntax errors of each file", (*context).check},
+		{"fmt", "[-w] [-l] [file...]", "format each file", (*context).fmt},
+		{"repl", "", "start the REPL, the default without arguments", (*context).repl},
+		{"serve", "[-http address]", "answer JSON-RPC requests on stdin, one per line, or over HTTP", (*context).serve},
+		{"help", "", "list the commands", (*context).help},
+	}
+}
+
+// context is what the commands of one invocation share.
+type context struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	trace          bool
+}
+
+// Main runs the monkey command with args, which exclude the program name,
+// and returns its exit status. A file named "-" is read from stdin, and
+// commands that take files read stdin when given none.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &context{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { c.usage(flags) }
+	code := flags.String("e", "", "parse `code` and print the program, as the REPL does")
+	dump := flags.String("dump", "", "print the AST of the given files (or stdin) as `sexpr` or dot, like the ast command")
+	flags.BoolVar(&c.trace, "trace-parse", false, "print the parser's Pratt decisions while parsing")
+	if err := flags.Parse(args); err != nil {
+		return usage_status(err)
+	}
+	args = flags.Args()
+
+	is_set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { is_set[f.Name] = true })
+
+	switch {
+	case is_set["e"]:
+		if len(args) != 0 {
+			fmt.Fprintln(stderr, "usage: monkey -e <code>")
+			return EXIT_USAGE
+		}
+		return c.evaluate("-e", *code)
+	case *dump != "":
+		return c.ast(append([]string{"-format", *dump}, args...))
+	case len(args) == 0:
+		return c.repl(nil)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(c, args[1:])
+		}
+	}
+	fmt.Fprintf(stderr, "monkey: unknown command %q, try monkey help\n", args[0])
+	return EXIT_USAGE
+}
+
+func (c *context) usage(flags *flag.FlagSet) {
+	fmt.Fprintln(c.stderr, "usage: monkey [flags] [command] [arguments]")
+	fmt.Fprintln(c.stderr, "\nflags:")
+	flags.PrintDefaults()
+	fmt.Fprintln(c.stderr, "\ncommands:")
+	c.list_commands(c.stderr)
+}
+
+func (c *context) list_commands(w io.Writer) {
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-38s %s\n", strings.TrimSpace(cmd.name+" "+cmd.usage), cmd.help)
+	}
+}
+
+// usage_status is the exit status for an error from parsing flags; asking
+// for help is not an error.
+func usage_status(err error) int {
+	if err == flag.ErrHelp {
+		return EXIT_OK
+	}
+	return EXIT_USAGE
+}
+
+// sub_flags returns the flag set of a command.
+func (c *context) sub_flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("monkey "+name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	return flags
+}
+
+func (c *context) run(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: monkey run <file>")
+		return EXIT_USAGE
+	}
+	name, source, ok := c.read(args[0])
+	if !ok {
+		return EXIT_FAILURE
+	}
+	return c.evaluate(name, source)
+}
+
+// evaluate parses source and prints the program. There is no evaluator
+// yet, so this is what the REPL prints for an entry.
+func (c *context) evaluate(name, source string) int {
+	program, ok := c.parse(name, source)
+	if !ok {
+		return EXIT_FAILURE
+	}
+	fmt.Fprintln(c.stdout, program.String())
+	return EXIT_OK
+}
+
+func (c *context) tokens(args []string) int {
+	return c.each_file(args, func(name, source string) bool {
+		file := token.New_file_set().Add_file(name, len(source))
+		for tok := range lexer.Tokens(source, lexer.With_file(file)) {
+			fmt.Fprintf(c.stdout, "%s\t%s\t%q\n", file.Position(tok.Pos), tok.Type, tok.Literal)
+		}
+		return true
+	})
+}
+
+func (c *context) ast(args []string) int {
+	flags := c.sub_flags("ast")
+	dump_format := flags.String("format", "sexpr", "print the tree as `sexpr` or dot")
+	if err := flags.Parse(args); err != nil {
+		return usage_status(err)
+	}
+
+	dump := astdump.Sexpr
+	switch *dump_format {
+	case "sexpr":
+	case "dot":
+		dump = astdump.Dot
+	default:
+		fmt.Fprintf(c.stderr, "unknown dump format %q, want sexpr or dot\n", *dump_format)
+		return EXIT_USAGE
+	}
+
+	return c.each_file(flags.Args(), func(name, source string) bool {
+		program, ok := c.parse(name, source)
+		if ok {
+			io.WriteString(c.stdout, dump(program))
+		}
+		return ok
+	})
+}
+
+func (c *context) check(args []string) int {
+	return c.each_file(args, func(name, source string) bool {
+		_, ok := c.parse(name, source)
+		return ok
+	})
+}
+
+func (c *context) fmt(args []string) int {
+	flags := c.sub_flags("fmt")
+	write := flags.Bool("w", false, "write the result back to the file instead of printing it")
+	list := flags.Bool("l", false, "list the files whose formatting differs, and fail if there are any")
+	if err := flags.Parse(args); err != nil {
+		return usage_status(err)
+	}
+
+	return c.each_file(flags.Args(), func(name, source string) bool {
+		if _, ok := c.parse(name, source); !ok {
+			return false
+		}
+		formatted, err := format.Source(source)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "%s: %v\n", name, err)
+			return false
+		}
+
+		changed := formatted != source
+		if *list && changed {
+			fmt.Fprintln(c.stdout, name)
+		}
+		if *write && name != "<stdin>" {
+			if changed {
+				if err := os.WriteFile(name, []byte(formatted), 0644); err != nil {
+					fmt.Fprintln(c.stderr, err)
+					return false
+				}
+			}
+		} else if !*list {
+			io.WriteString(c.stdout, formatted)
+		}
+		return !(*list && changed)
+	})
+}
+
+func (c *context) repl(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(c.stderr, "usage: monkey repl")
+		return EXIT_USAGE
+	}
+
+	opts := repl.Options{Trace_parse: c.trace, Color: use_color(c.stdout)}
+	if home, err := os.UserHomeDir(); err == nil {
+		opts.History_file = filepath.Join(home, ".monkey_history")
+	}
+
+	if f, ok := c.stdin.(*os.File); ok && term.Is_terminal(int(f.Fd())) {
+		name := ""
+		if u, err := user.Current(); err == nil {
+			name = " " + u.Username
+		}
+		fmt.Fprintf(c.stdout, "Hello%s! This is the Monkey programming language!\n", name)
+		fmt.Fprintf(c.stdout, "Feel free to type in commands\n")
+	}
+	repl.Start_with(c.stdin, c.stdout, opts)
+	return EXIT_OK
+}
+
+func (c *context) serve(args []string) int {
+	flags := c.sub_flags("serve")
+	address := flags.String("http", "", "listen for HTTP POST requests on `address` instead of reading stdin")
+	if err := flags.Parse(args); err != nil {
+		return usage_status(err)
+	}
+
+	server := rpc.New()
+	var err error
+	if *address != "" {
+		err = rpc.New_http_server(*address, server).ListenAndServe()
+	} else {
+		err = server.Serve_stream(c.stdin, c.stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
+
+func (c *context) help(args []string) int {
+	fmt.Fprintln(c.stdout, "usage: monkey [flags] [command] [arguments]\n\ncommands:")
+	c.list_commands(c.stdout)
+	return EXIT_OK
+}
+
+// each_file calls f with the name and source of each file in names, or of
+// stdin when there are none, and fails if any call or read fails.
+func (c *context) each_file(names []string, f func(name, source string) bool) int {
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+
+	status := EXIT_OK
+	for _, path := range names {
+		name, source, ok := c.read(path)
+		if !ok || !f(name, source) {
+			status = EXIT_FAILURE
+		}
+	}
+	return status
+}
+
+// read returns the contents of the file at path, "-" being stdin, and the
+// name to report errors in it with.
+func (c *context) read(path string) (string, string, bool) {
+	var data []byte
+	var err error
+	if path == "-" {
+		path = "<stdin>"
+		data, err = io.ReadAll(c.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return path, "", false
+	}
+	return path, string(data), true
+}
+
+// parse parses source, reporting any errors on stderr.
+func (c *context) parse(name, source string) (*ast.Program, bool) {
+	var opts []parser.Option
+	if c.trace {
+		opts = append(opts, parser.With_trace(c.stderr))
+	}
+	file := token.New_file_set().Add_file(name, len(source))
+	p := parser.New(lexer.New(source, lexer.With_file(file)), opts...)
+	program := p.Parse_program()
+
+	if len(p.Errors()) != 0 {
+		printer := diag.New(file, source)
+		printer.Color = use_color(c.stderr)
+		printer.Fprint(c.stderr, p.Parse_errors())
+		return nil, false
+	}
+	return program, true
+}
+
+func use_color(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && diag.Use_color(f)
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type result struct {
+	status int
+	stdout string
+	stderr string
+}
+
+func run(stdin string, args ...string) result {
+	var stdout, stderr strings.Builder
+	status := Main(args, strings.NewReader(stdin), &stdout, &stderr)
+	return result{status, stdout.String(), stderr.String()}
+}
+
+func write_file(t *testing.T, name, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommands(t *testing.T) {
+	good := write_file(t, "good.mk", "#!/usr/bin/env monkey\nlet x=1\nputs( x )\n")
+	bad := write_file(t, "bad.mk", "let x = 1\nlet = 2\n")
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{"run", good}, "", EXIT_OK, "let x = 1;puts(x)\n", ""},
+		{[]string{"run", bad}, "", EXIT_FAILURE, "", " --> " + bad + ":2:5\n"},
+		{[]string{"run", "-"}, "1 + 2", EXIT_OK, "(1 + 2)\n", ""},
+		{[]string{"run", good + ".missing"}, "", EXIT_FAILURE, "", "no such file or directory"},
+		{[]string{"run"}, "", EXIT_USAGE, "", "usage: monkey run <file>"},
+		{[]string{"-e", "-a * b"}, "", EXIT_OK, "((-a) * b)\n", ""},
+		{[]string{"-e", "let"}, "", EXIT_FAILURE, "", " --> -e:1:4\n"},
+		{[]string{"-e", "1", "extra"}, "", EXIT_USAGE, "", "usage: monkey -e <code>"},
+		{[]string{"tokens"}, "let x", EXIT_OK, "<stdin>:1:1\tLET\t\"let\"\n<stdin>:1:5\tIDENT\t\"x\"\n", ""},
+		{[]string{"ast", "-"}, "x", EXIT_OK, "(program\n  (expr\n    (ident x)))\n", ""},
+		{[]string{"ast", "-format", "dot"}, "x", EXIT_OK, "digraph ast {", ""},
+		{[]string{"ast", "-format", "json"}, "x", EXIT_USAGE, "", "unknown dump format \"json\""},
+		{[]string{"-dump", "sexpr"}, "x", EXIT_OK, "(ident x)", ""},
+		{[]string{"check", good}, "", EXIT_OK, "", ""},
+		{[]string{"check", good, bad}, "", EXIT_FAILURE, "", "error: expected an identifier, found `=`"},
+		{[]string{"check", "-"}, "if (x {", EXIT_FAILURE, "", " --> <stdin>:1:7\n"},
+		{[]string{"fmt", good}, "", EXIT_OK, "#!/usr/bin/env monkey\nlet x = 1\nputs(x)\n", ""},
+		{[]string{"fmt", "-l", good}, "", EXIT_FAILURE, good + "\n", ""},
+		{[]string{"fmt", bad}, "", EXIT_FAILURE, "", "error: "},
+		{[]string{"fmt"}, "let  y=2;", EXIT_OK, "let y = 2;\n", ""},
+		{[]string{"-trace-parse", "check"}, "1", EXIT_OK, "", "BEGIN parse_expression(LOWEST)"},
+		{[]string{}, "let y = 2\n", EXIT_OK, ">> let y = 2;\n>> ", ""},
+		{[]string{"repl"}, ":tokens x\n", EXIT_OK, "IDENT", ""},
+		{[]string{"serve"}, `{"jsonrpc": "2.0", "id": 1, "method": "format", "params": {"source": "x+1"}}` + "\n",
+			EXIT_OK, `{"jsonrpc":"2.0","result":{"source":"x + 1\n"},"id":1}` + "\n", ""},
+		{[]string{"help"}, "", EXIT_OK, "fmt [-w] [-l] [file...]", ""},
+		{[]string{"-h"}, "", EXIT_OK, "", "usage: monkey [flags] [command] [arguments]"},
+		{[]string{"-bogus"}, "", EXIT_USAGE, "", "flag provided but not defined: -bogus"},
+		{[]string{"bogus"}, "", EXIT_USAGE, "", "unknown command \"bogus\""},
+	}
+
+	for _, tt := range tests {
+		got := run(tt.stdin, tt.args...)
+		if got.status != tt.status {
+			t.Errorf("monkey %q: wrong status. expected=%d, got=%d (stderr=%q)", tt.args, tt.status, got.status, got.stderr)
+		}
+		if !strings.Contains(got.stdout, tt.stdout) || tt.stdout == "" && got.stdout != "" {
+			t.Errorf("monkey %q: wrong stdout. expected=%q, got=%q", tt.args, tt.stdout, got.stdout)
+		}
+		if !strings.Contains(got.stderr, tt.stderr) || tt.stderr == "" && got.stderr != "" {
+			t.Errorf("monkey %q: wrong stderr. expected=%q, got=%q", tt.args, tt.stderr, got.stderr)
+		}
+	}
+}
+
+func TestFmtWrite(t *testing.T) {
+	messy := write_file(t, "messy.mk", "let f=fn(x){\nx*2\n}\n")
+	tidy := write_file(t, "tidy.mk", "let y = 1\n")
+
+	if got := run("", "fmt", "-w", messy, tidy); got.status != EXIT_OK || got.stdout != "" {
+		t.Fatalf("fmt -w failed: %+v", got)
+	}
+
+	source, _ := os.ReadFile(messy)
+	if string(source) != "let f = fn(x) {\n\tx * 2\n}\n" {
+		t.Errorf("file not formatted. got=%q", source)
+	}
+	if got := run("", "fmt", "-l", messy, tidy); got.status != EXIT_OK || got.stdout != "" {
+		t.Errorf("formatted files listed: %+v", got)
+	}
+}
+
+func TestNoGreetingFromDevNull(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer null.Close()
+
+	var stdout, stderr strings.Builder
+	if status := Main(nil, null, &stdout, &stderr); status != EXIT_OK {
+		t.Fatalf("wrong status %d: %s", status, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "Hello") {
+		t.Errorf("greeted a non-terminal stdin: %q", stdout.String())
+	}
+}
@@ -0,0 +1,3 @@
+module monkey/cli
+
+go 1.24.1
@@ -0,0 +1,194 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is bytecode: each instruction is an opcode byte followed by
+// its operands, big-endian, in the widths its Definition gives.
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	// OP_CONSTANT pushes the constant at its operand's index in the pool.
+	OP_CONSTANT Opcode = iota
+	OP_POP
+
+	OP_ADD
+	OP_SUB
+	OP_MUL
+	OP_DIV
+
+	OP_TRUE
+	OP_FALSE
+	OP_NULL
+
+	// There is no less-than: the compiler swaps the operands of a < b.
+	OP_EQUAL
+	OP_NOT_EQUAL
+	OP_GREATER_THAN
+
+	OP_MINUS
+	OP_BANG
+
+	// The jumps take an absolute instruction offset.
+	OP_JUMP_NOT_TRUTHY
+	OP_JUMP
+
+	OP_GET_GLOBAL
+	OP_SET_GLOBAL
+	OP_GET_LOCAL
+	OP_SET_LOCAL
+	OP_GET_FREE
+
+	// OP_CALL calls the function below its operand's number of arguments.
+	OP_CALL
+	OP_RETURN_VALUE
+	// OP_RETURN returns from a function without a value.
+	OP_RETURN
+
+	// OP_CLOSURE wraps the function constant at its first operand's index
+	// with the number of free variables given by its second operand, which
+	// are taken off the stack.
+	OP_CLOSURE
+	// OP_CURRENT_CLOSURE pushes the closure being executed, so that a
+	// function can call itself.
+	OP_CURRENT_CLOSURE
+)
+
+type Definition struct {
+	Name           string
+	Operand_widths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OP_CONSTANT:        {"OP_CONSTANT", []int{2}},
+	OP_POP:             {"OP_POP", []int{}},
+	OP_ADD:             {"OP_ADD", []int{}},
+	OP_SUB:             {"OP_SUB", []int{}},
+	OP_MUL:             {"OP_MUL", []int{}},
+	OP_DIV:             {"OP_DIV", []int{}},
+	OP_TRUE:            {"OP_TRUE", []int{}},
+	OP_FALSE:           {"OP_FALSE", []int{}},
+	OP_NULL:            {"OP_NULL", []int{}},
+	OP_EQUAL:           {"OP_EQUAL", []int{}},
+	OP_NOT_EQUAL:       {"OP_NOT_EQUAL", []int{}},
+	OP_GREATER_THAN:    {"OP_GREATER_THAN", []int{}},
+	OP_MINUS:           {"OP_MINUS", []int{}},
+	OP_BANG:            {"OP_BANG", []int{}},
+	OP_JUMP_NOT_TRUTHY: {"OP_JUMP_NOT_TRUTHY", []int{2}},
+	OP_JUMP:            {"OP_JUMP", []int{2}},
+	OP_GET_GLOBAL:      {"OP_GET_GLOBAL", []int{2}},
+	OP_SET_GLOBAL:      {"OP_SET_GLOBAL", []int{2}},
+	OP_GET_LOCAL:       {"OP_GET_LOCAL", []int{1}},
+	OP_SET_LOCAL:       {"OP_SET_LOCAL", []int{1}},
+	OP_GET_FREE:        {"OP_GET_FREE", []int{1}},
+	OP_CALL:            {"OP_CALL", []int{1}},
+	OP_RETURN_VALUE:    {"OP_RETURN_VALUE", []int{}},
+	OP_RETURN:          {"OP_RETURN", []int{}},
+	OP_CLOSURE:         {"OP_CLOSURE", []int{2, 1}},
+	OP_CURRENT_CLOSURE: {"OP_CURRENT_CLOSURE", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes an instruction. It returns nil for an unknown opcode and
+// truncates operands that do not fit their width.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return nil
+	}
+
+	instruction := make([]byte, 1+operands_width(def))
+	instruction[0] = byte(op)
+	offset := 1
+	for i, operand := range operands[:min(len(operands), len(def.Operand_widths))] {
+		width := def.Operand_widths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
+		}
+		offset += width
+	}
+	return instruction
+}
+
+// Read_operands decodes the operands of an instruction of type def from
+// the start of ins and returns them with the number of bytes they took.
+func Read_operands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.Operand_widths))
+	offset := 0
+	for i, width := range def.Operand_widths {
+		switch width {
+		case 2:
+			operands[i] = int(Read_uint16(ins[offset:]))
+		case 1:
+			operands[i] = int(Read_uint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func Read_uint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func Read_uint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// String disassembles the instructions, one per line with its offset:
+//
+//	0000 OP_CONSTANT 1
+//	0003 OP_POP
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		if i+1+operands_width(def) > len(ins) {
+			fmt.Fprintf(&out, "ERROR: %s at %04d is truncated\n", def.Name, i)
+			break
+		}
+
+		operands, read := Read_operands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, format_instruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func operands_width(def *Definition) int {
+	width := 0
+	for _, w := range def.Operand_widths {
+		width += w
+	}
+	return width
+}
+
+func format_instruction(def *Definition, operands []int) string {
+	text := def.Name
+	for _, operand := range operands {
+		text += fmt.Sprintf(" %d", operand)
+	}
+	return text
+}
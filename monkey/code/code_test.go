@@ -0,0 +1,92 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OP_CONSTANT, []int{65534}, []byte{byte(OP_CONSTANT), 255, 254}},
+		{OP_ADD, []int{}, []byte{byte(OP_ADD)}},
+		{OP_GET_LOCAL, []int{255}, []byte{byte(OP_GET_LOCAL), 255}},
+		{OP_CLOSURE, []int{65534, 255}, []byte{byte(OP_CLOSURE), 255, 254, 255}},
+		{OP_CONSTANT, []int{}, []byte{byte(OP_CONSTANT), 0, 0}},
+		{Opcode(255), []int{1}, nil},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		if string(instruction) != string(tt.expected) || (instruction == nil) != (tt.expected == nil) {
+			t.Errorf("Make(%d, %v) wrong. expected=%v, got=%v", tt.op, tt.operands, tt.expected, instruction)
+		}
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OP_CONSTANT, []int{65535}, 2},
+		{OP_GET_LOCAL, []int{255}, 1},
+		{OP_CLOSURE, []int{65535, 255}, 3},
+		{OP_POP, []int{}, 0},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q", err)
+		}
+
+		operands, n := Read_operands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. expected=%d, got=%d", tt.bytesRead, n)
+		}
+		for i, want := range tt.operands {
+			if operands[i] != want {
+				t.Errorf("operand %d wrong. expected=%d, got=%d", i, want, operands[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OP_ADD),
+		Make(OP_GET_LOCAL, 1),
+		Make(OP_CONSTANT, 2),
+		Make(OP_CONSTANT, 65535),
+		Make(OP_CLOSURE, 65535, 255),
+	}
+
+	expected := `0000 OP_ADD
+0001 OP_GET_LOCAL 1
+0003 OP_CONSTANT 2
+0006 OP_CONSTANT 65535
+0009 OP_CLOSURE 65535 255
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nexpected=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestMalformedInstructionsString(t *testing.T) {
+	ins := append(Instructions{200}, Make(OP_CONSTANT, 1)[:2]...)
+
+	expected := "ERROR: opcode 200 undefined\nERROR: OP_CONSTANT at 0001 is truncated\n"
+	if ins.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, ins.String())
+	}
+}
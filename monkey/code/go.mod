@@ -0,0 +1,3 @@
+module monkey/code
+
+go 1.24.1
@@ -0,0 +1,438 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"strconv"
+)
+
+// Constant is an entry of the constant pool: an Integer or a *Function.
+type Constant interface {
+	String() string
+	constant()
+}
+
+type Integer int64
+
+func (i Integer) String() string { return strconv.FormatInt(int64(i), 10) }
+func (Integer) constant()        {}
+
+// Function is a compiled function literal. The VM wraps it in a closure
+// with OP_CLOSURE.
+type Function struct {
+	Instructions   code.Instructions
+	Num_locals     int
+	Num_parameters int
+}
+
+func (f *Function) String() string {
+	return fmt.Sprintf("fn/%d", f.Num_parameters)
+}
+func (*Function) constant() {}
+
+// Bytecode is the output of a Compiler: the instructions of the program
+// and the constants they refer to by index.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []Constant
+}
+
+type emitted_instruction struct {
+	opcode   code.Opcode
+	position int
+}
+
+// compilation_scope holds the instructions of the program or of the
+// function literal being compiled.
+type compilation_scope struct {
+	instructions code.Instructions
+	last         emitted_instruction
+	previous     emitted_instruction
+}
+
+type Compiler struct {
+	constants    []Constant
+	symbol_table *Symbol_table
+	scopes       []compilation_scope
+}
+
+func New() *Compiler {
+	return New_with_state(New_symbol_table(), nil)
+}
+
+// New_with_state returns a compiler that continues where another left
+// off, as the entries of a REPL session do: with its globals and
+// constants.
+func New_with_state(symbol_table *Symbol_table, constants []Constant) *Compiler {
+	return &Compiler{
+		constants:    constants,
+		symbol_table: symbol_table,
+		scopes:       []compilation_scope{{}},
+	}
+}
+
+// Symbol_table returns the table of the globals defined so far.
+func (c *Compiler) Symbol_table() *Symbol_table {
+	return c.symbol_table
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{Instructions: c.current_instructions(), Constants: c.constants}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.Expression_statement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OP_POP)
+
+	case *ast.Block_statement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.Let_statement:
+		if err := c.compile_let(node); err != nil {
+			return err
+		}
+
+	case *ast.Return_statement:
+		if err := c.Compile(node.Return_value); err != nil {
+			return err
+		}
+		c.emit(code.OP_RETURN_VALUE)
+
+	case *ast.Prefix_expression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OP_BANG)
+		case "-":
+			c.emit(code.OP_MINUS)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.Infix_expression:
+		if err := c.compile_infix(node); err != nil {
+			return err
+		}
+
+	case *ast.If_expression:
+		if err := c.compile_if(node); err != nil {
+			return err
+		}
+
+	case *ast.Integer_literal:
+		return c.emit_constant(Integer(node.Value))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OP_TRUE)
+		} else {
+			c.emit(code.OP_FALSE)
+		}
+
+	case *ast.Identifier:
+		symbol, ok := c.symbol_table.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("undefined variable %s", node.Value)
+		}
+		c.load_symbol(symbol)
+
+	case *ast.Function_literal:
+		return c.compile_function(node, "")
+
+	case *ast.Call_expression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		if len(node.Arguments) > 255 {
+			return fmt.Errorf("too many arguments, the limit is 255")
+		}
+		for _, argument := range node.Arguments {
+			if err := c.Compile(argument); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OP_CALL, len(node.Arguments))
+
+	case nil:
+		return fmt.Errorf("cannot compile a missing node")
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compile_let(node *ast.Let_statement) error {
+	var err error
+	if fn, ok := node.Value.(*ast.Function_literal); ok {
+		err = c.compile_function(fn, node.Name.Value)
+	} else {
+		err = c.Compile(node.Value)
+	}
+	if err != nil {
+		return err
+	}
+
+	symbol := c.symbol_table.Define(node.Name.Value)
+	if symbol.Scope == GLOBAL_SCOPE {
+		if symbol.Index > 65535 {
+			return fmt.Errorf("too many globals, the limit is 65536")
+		}
+		c.emit(code.OP_SET_GLOBAL, symbol.Index)
+	} else {
+		if symbol.Index > 255 {
+			return fmt.Errorf("too many locals, the limit is 256")
+		}
+		c.emit(code.OP_SET_LOCAL, symbol.Index)
+	}
+	return nil
+}
+
+func (c *Compiler) compile_infix(node *ast.Infix_expression) error {
+	// a < b is compiled as b > a.
+	left, right := node.Left, node.Right
+	if node.Operator == "<" {
+		left, right = right, left
+	}
+	if err := c.Compile(left); err != nil {
+		return err
+	}
+	if err := c.Compile(right); err != nil {
+		return err
+	}
+
+	switch node.Operator {
+	case "+":
+		c.emit(code.OP_ADD)
+	case "-":
+		c.emit(code.OP_SUB)
+	case "*":
+		c.emit(code.OP_MUL)
+	case "/":
+		c.emit(code.OP_DIV)
+	case ">", "<":
+		c.emit(code.OP_GREATER_THAN)
+	case "==":
+		c.emit(code.OP_EQUAL)
+	case "!=":
+		c.emit(code.OP_NOT_EQUAL)
+	default:
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+	return nil
+}
+
+// compile_if leaves the value of the branch taken on the stack, null when
+// there is no else branch. The jumps are emitted with a placeholder
+// offset and patched once the code they jump over is compiled.
+func (c *Compiler) compile_if(node *ast.If_expression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jump_not_truthy := c.emit(code.OP_JUMP_NOT_TRUTHY, 9999)
+
+	if err := c.compile_branch(node.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OP_JUMP, 9999)
+
+	if err := c.patch_jump(jump_not_truthy); err != nil {
+		return err
+	}
+
+	if node.Alternative == nil {
+		c.emit(code.OP_NULL)
+	} else if err := c.compile_branch(node.Alternative); err != nil {
+		return err
+	}
+
+	return c.patch_jump(jump)
+}
+
+// patch_jump makes the jump at position jump to the end of the current
+// instructions.
+func (c *Compiler) patch_jump(position int) error {
+	target := len(c.current_instructions())
+	if target > 65535 {
+		return fmt.Errorf("jump target %d is too far, the limit is 65535", target)
+	}
+	c.change_operand(position, target)
+	return nil
+}
+
+// compile_branch compiles a block so that it leaves its value on the
+// stack: that of its last expression statement, or null.
+func (c *Compiler) compile_branch(block *ast.Block_statement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if c.last_instruction_is(code.OP_POP) {
+		c.remove_last_instruction()
+	} else {
+		c.emit(code.OP_NULL)
+	}
+	return nil
+}
+
+// compile_function compiles a function literal into a constant and emits
+// the closure over its free variables. Inside the body, name refers to
+// the function itself.
+func (c *Compiler) compile_function(node *ast.Function_literal, name string) error {
+	if len(node.Parameters) > 255 {
+		return fmt.Errorf("too many parameters, the limit is 255")
+	}
+
+	c.enter_scope()
+	err := c.compile_body(node, name)
+	free_symbols := c.symbol_table.Free_symbols
+	num_locals := c.symbol_table.Num_definitions
+	instructions := c.leave_scope()
+	if err != nil {
+		return err
+	}
+
+	if len(free_symbols) > 255 {
+		return fmt.Errorf("too many free variables, the limit is 255")
+	}
+	if num_locals > 256 {
+		return fmt.Errorf("too many locals, the limit is 256")
+	}
+	for _, symbol := range free_symbols {
+		c.load_symbol(symbol)
+	}
+
+	index, err := c.add_constant(&Function{
+		Instructions:   instructions,
+		Num_locals:     num_locals,
+		Num_parameters: len(node.Parameters),
+	})
+	if err != nil {
+		return err
+	}
+	c.emit(code.OP_CLOSURE, index, len(free_symbols))
+	return nil
+}
+
+// compile_body compiles the parameters and body of a function literal in
+// the scope compile_function entered for it. The function returns the
+// value of its last expression statement, or nothing.
+func (c *Compiler) compile_body(node *ast.Function_literal, name string) error {
+	if name != "" {
+		c.symbol_table.Define_function_name(name)
+	}
+	for _, parameter := range node.Parameters {
+		c.symbol_table.Define(parameter.Value)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	if c.last_instruction_is(code.OP_POP) {
+		c.replace_last_pop_with_return()
+	}
+	if !c.last_instruction_is(code.OP_RETURN_VALUE) {
+		c.emit(code.OP_RETURN)
+	}
+	return nil
+}
+
+func (c *Compiler) load_symbol(s Symbol) {
+	switch s.Scope {
+	case GLOBAL_SCOPE:
+		c.emit(code.OP_GET_GLOBAL, s.Index)
+	case LOCAL_SCOPE:
+		c.emit(code.OP_GET_LOCAL, s.Index)
+	case FREE_SCOPE:
+		c.emit(code.OP_GET_FREE, s.Index)
+	case FUNCTION_SCOPE:
+		c.emit(code.OP_CURRENT_CLOSURE)
+	}
+}
+
+func (c *Compiler) add_constant(constant Constant) (int, error) {
+	if len(c.constants) > 65535 {
+		return 0, fmt.Errorf("too many constants, the limit is 65536")
+	}
+	c.constants = append(c.constants, constant)
+	return len(c.constants) - 1, nil
+}
+
+func (c *Compiler) emit_constant(constant Constant) error {
+	index, err := c.add_constant(constant)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OP_CONSTANT, index)
+	return nil
+}
+
+// emit appends an instruction to the current scope and returns its
+// position.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := &c.scopes[len(c.scopes)-1]
+	position := len(scope.instructions)
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+
+	scope.previous = scope.last
+	scope.last = emitted_instruction{opcode: op, position: position}
+	return position
+}
+
+func (c *Compiler) current_instructions() code.Instructions {
+	return c.scopes[len(c.scopes)-1].instructions
+}
+
+func (c *Compiler) last_instruction_is(op code.Opcode) bool {
+	scope := c.scopes[len(c.scopes)-1]
+	return len(scope.instructions) > 0 && scope.last.opcode == op
+}
+
+func (c *Compiler) remove_last_instruction() {
+	scope := &c.scopes[len(c.scopes)-1]
+	scope.instructions = scope.instructions[:scope.last.position]
+	scope.last = scope.previous
+}
+
+func (c *Compiler) replace_last_pop_with_return() {
+	scope := &c.scopes[len(c.scopes)-1]
+	copy(scope.instructions[scope.last.position:], code.Make(code.OP_RETURN_VALUE))
+	scope.last.opcode = code.OP_RETURN_VALUE
+}
+
+// change_operand patches the operand of the instruction at position.
+func (c *Compiler) change_operand(position int, operand int) {
+	instructions := c.current_instructions()
+	op := code.Opcode(instructions[position])
+	copy(instructions[position:], code.Make(op, operand))
+}
+
+func (c *Compiler) enter_scope() {
+	c.scopes = append(c.scopes, compilation_scope{})
+	c.symbol_table = New_enclosed_symbol_table(c.symbol_table)
+}
+
+func (c *Compiler) leave_scope() code.Instructions {
+	instructions := c.current_instructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbol_table = c.symbol_table.Outer
+	return instructions
+}
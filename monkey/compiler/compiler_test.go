@@ -0,0 +1,567 @@
+package compiler
+
+import (
+	"monkey/ast"
+	"monkey/code"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+type compiler_test_case struct {
+	input                string
+	expectedConstants    []any
+	expectedInstructions []code.Instructions
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.Parse_program()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func run_compiler_tests(t *testing.T, tests []compiler_test_case) {
+	t.Helper()
+
+	for _, tt := range tests {
+		compiler := New()
+		if err := compiler.Compile(parse(t, tt.input)); err != nil {
+			t.Fatalf("compiler error for %q: %s", tt.input, err)
+		}
+
+		bytecode := compiler.Bytecode()
+		test_instructions(t, tt.input, tt.expectedInstructions, bytecode.Instructions)
+		test_constants(t, tt.input, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func concat_instructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func test_instructions(t *testing.T, input string, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+	concatted := concat_instructions(expected)
+	if actual.String() != concatted.String() {
+		t.Errorf("input %q: wrong instructions.\nwant=\n%s\ngot=\n%s", input, concatted, actual)
+	}
+}
+
+// test_constants compares the constant pool with expected, whose entries
+// are ints for integers and instruction lists for functions.
+func test_constants(t *testing.T, input string, expected []any, actual []Constant) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Errorf("input %q: wrong number of constants. want=%d, got=%d", input, len(expected), len(actual))
+		return
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			if integer, ok := actual[i].(Integer); !ok || int64(integer) != int64(constant) {
+				t.Errorf("input %q: constant %d wrong. want=%d, got=%#v", input, i, constant, actual[i])
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*Function)
+			if !ok {
+				t.Errorf("input %q: constant %d is not a function. got=%#v", input, i, actual[i])
+				continue
+			}
+			test_instructions(t, input, constant, fn.Instructions)
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input:             "1 + 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_CONSTANT, 1),
+				code.Make(code.OP_ADD),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_POP),
+				code.Make(code.OP_CONSTANT, 1),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "1 - 2 * 3 / 4",
+			expectedConstants: []any{1, 2, 3, 4},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_CONSTANT, 1),
+				code.Make(code.OP_CONSTANT, 2),
+				code.Make(code.OP_MUL),
+				code.Make(code.OP_CONSTANT, 3),
+				code.Make(code.OP_DIV),
+				code.Make(code.OP_SUB),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "-1",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_MINUS),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input:             "true",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_TRUE),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "1 > 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_CONSTANT, 1),
+				code.Make(code.OP_GREATER_THAN),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "1 < 2",
+			expectedConstants: []any{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_CONSTANT, 1),
+				code.Make(code.OP_GREATER_THAN),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "true != false == !true",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_TRUE),
+				code.Make(code.OP_FALSE),
+				code.Make(code.OP_NOT_EQUAL),
+				code.Make(code.OP_TRUE),
+				code.Make(code.OP_BANG),
+				code.Make(code.OP_EQUAL),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []any{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OP_TRUE),
+				// 0001
+				code.Make(code.OP_JUMP_NOT_TRUTHY, 10),
+				// 0004
+				code.Make(code.OP_CONSTANT, 0),
+				// 0007
+				code.Make(code.OP_JUMP, 11),
+				// 0010
+				code.Make(code.OP_NULL),
+				// 0011
+				code.Make(code.OP_POP),
+				// 0012
+				code.Make(code.OP_CONSTANT, 1),
+				// 0015
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "if (true) { 10 } else { 20 }; 3333;",
+			expectedConstants: []any{10, 20, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OP_TRUE),
+				// 0001
+				code.Make(code.OP_JUMP_NOT_TRUTHY, 10),
+				// 0004
+				code.Make(code.OP_CONSTANT, 0),
+				// 0007
+				code.Make(code.OP_JUMP, 13),
+				// 0010
+				code.Make(code.OP_CONSTANT, 1),
+				// 0013
+				code.Make(code.OP_POP),
+				// 0014
+				code.Make(code.OP_CONSTANT, 2),
+				// 0017
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input:             "if (false) { } else { let x = 1 }",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OP_FALSE),
+				// 0001
+				code.Make(code.OP_JUMP_NOT_TRUTHY, 8),
+				// 0004
+				code.Make(code.OP_NULL),
+				// 0005
+				code.Make(code.OP_JUMP, 15),
+				// 0008
+				code.Make(code.OP_CONSTANT, 0),
+				// 0011
+				code.Make(code.OP_SET_GLOBAL, 0),
+				// 0014
+				code.Make(code.OP_NULL),
+				// 0015
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input:             "let one = 1; let two = one; two;",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_SET_GLOBAL, 0),
+				code.Make(code.OP_GET_GLOBAL, 0),
+				code.Make(code.OP_SET_GLOBAL, 1),
+				code.Make(code.OP_GET_GLOBAL, 1),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input: "fn() { return 5 + 10 }",
+			expectedConstants: []any{
+				5,
+				10,
+				[]code.Instructions{
+					code.Make(code.OP_CONSTANT, 0),
+					code.Make(code.OP_CONSTANT, 1),
+					code.Make(code.OP_ADD),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 2, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input: "fn() { 1; 2 }",
+			expectedConstants: []any{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OP_CONSTANT, 0),
+					code.Make(code.OP_POP),
+					code.Make(code.OP_CONSTANT, 1),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 2, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OP_RETURN),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 0, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestFunctionCalls(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input: "fn() { 24 }();",
+			expectedConstants: []any{
+				24,
+				[]code.Instructions{
+					code.Make(code.OP_CONSTANT, 0),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 1, 0),
+				code.Make(code.OP_CALL, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input: "let many = fn(a, b, c) { a; b; c }; many(24, 25, 26);",
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_POP),
+					code.Make(code.OP_GET_LOCAL, 1),
+					code.Make(code.OP_POP),
+					code.Make(code.OP_GET_LOCAL, 2),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+				24,
+				25,
+				26,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 0, 0),
+				code.Make(code.OP_SET_GLOBAL, 0),
+				code.Make(code.OP_GET_GLOBAL, 0),
+				code.Make(code.OP_CONSTANT, 1),
+				code.Make(code.OP_CONSTANT, 2),
+				code.Make(code.OP_CONSTANT, 3),
+				code.Make(code.OP_CALL, 3),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestLetStatementScopes(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input: "let num = 55; fn() { num }",
+			expectedConstants: []any{
+				55,
+				[]code.Instructions{
+					code.Make(code.OP_GET_GLOBAL, 0),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_SET_GLOBAL, 0),
+				code.Make(code.OP_CLOSURE, 1, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input: "fn() { let a = 55; let b = 77; a + b }",
+			expectedConstants: []any{
+				55,
+				77,
+				[]code.Instructions{
+					code.Make(code.OP_CONSTANT, 0),
+					code.Make(code.OP_SET_LOCAL, 0),
+					code.Make(code.OP_CONSTANT, 1),
+					code.Make(code.OP_SET_LOCAL, 1),
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_GET_LOCAL, 1),
+					code.Make(code.OP_ADD),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 2, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []any{
+				[]code.Instructions{
+					code.Make(code.OP_GET_FREE, 0),
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_ADD),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+				[]code.Instructions{
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_CLOSURE, 0, 1),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 1, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+		{
+			input: "let global = 55; fn(a) { let b = 66; fn(c) { fn(d) { global + a + b + c + d } } }",
+			expectedConstants: []any{
+				55,
+				66,
+				[]code.Instructions{
+					code.Make(code.OP_GET_GLOBAL, 0),
+					code.Make(code.OP_GET_FREE, 0),
+					code.Make(code.OP_ADD),
+					code.Make(code.OP_GET_FREE, 1),
+					code.Make(code.OP_ADD),
+					code.Make(code.OP_GET_FREE, 2),
+					code.Make(code.OP_ADD),
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_ADD),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+				[]code.Instructions{
+					code.Make(code.OP_GET_FREE, 0),
+					code.Make(code.OP_GET_FREE, 1),
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_CLOSURE, 2, 3),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+				[]code.Instructions{
+					code.Make(code.OP_CONSTANT, 1),
+					code.Make(code.OP_SET_LOCAL, 1),
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_GET_LOCAL, 1),
+					code.Make(code.OP_CLOSURE, 3, 2),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CONSTANT, 0),
+				code.Make(code.OP_SET_GLOBAL, 0),
+				code.Make(code.OP_CLOSURE, 4, 0),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []compiler_test_case{
+		{
+			input: "let count_down = fn(x) { count_down(x - 1) }; count_down(1);",
+			expectedConstants: []any{
+				1,
+				[]code.Instructions{
+					code.Make(code.OP_CURRENT_CLOSURE),
+					code.Make(code.OP_GET_LOCAL, 0),
+					code.Make(code.OP_CONSTANT, 0),
+					code.Make(code.OP_SUB),
+					code.Make(code.OP_CALL, 1),
+					code.Make(code.OP_RETURN_VALUE),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OP_CLOSURE, 1, 0),
+				code.Make(code.OP_SET_GLOBAL, 0),
+				code.Make(code.OP_GET_GLOBAL, 0),
+				code.Make(code.OP_CONSTANT, 2),
+				code.Make(code.OP_CALL, 1),
+				code.Make(code.OP_POP),
+			},
+		},
+	}
+
+	run_compiler_tests(t, tests)
+}
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "undefined variable x"},
+		{"let f = fn() { y }", "undefined variable y"},
+		{"let x = x", "undefined variable x"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(t, tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("input %q: wrong error. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestJumpTooFar(t *testing.T) {
+	input := "if (true) { " + strings.Repeat("true; ", 40000) + "}; 7"
+
+	err := New().Compile(parse(t, input))
+	expected := "jump target 80006 is too far, the limit is 65535"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%v", expected, err)
+	}
+}
+
+func TestCompilerState(t *testing.T) {
+	first := New()
+	if err := first.Compile(parse(t, "let a = 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Compile(parse(t, "let f = fn() { g }")); err == nil {
+		t.Fatal("undefined variable not reported")
+	}
+
+	// A failed entry leaves the compiler at the top level, so the next
+	// one can still use the globals.
+	second := New_with_state(first.Symbol_table(), first.Bytecode().Constants)
+	if err := second.Compile(parse(t, "a + 2")); err != nil {
+		t.Fatal(err)
+	}
+	test_instructions(t, "a + 2", []code.Instructions{
+		code.Make(code.OP_GET_GLOBAL, 0),
+		code.Make(code.OP_CONSTANT, 1),
+		code.Make(code.OP_ADD),
+		code.Make(code.OP_POP),
+	}, second.Bytecode().Instructions)
+	test_constants(t, "a + 2", []any{1, 2}, second.Bytecode().Constants)
+}
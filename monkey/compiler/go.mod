@@ -0,0 +1,3 @@
+module monkey/compiler
+
+go 1.24.1
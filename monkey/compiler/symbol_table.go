@@ -0,0 +1,89 @@
+package compiler
+
+type Symbol_scope string
+
+const (
+	GLOBAL_SCOPE Symbol_scope = "GLOBAL"
+	LOCAL_SCOPE  Symbol_scope = "LOCAL"
+	// FREE_SCOPE is a local of an enclosing function, captured by a
+	// closure.
+	FREE_SCOPE Symbol_scope = "FREE"
+	// FUNCTION_SCOPE is the name a function literal is bound to, which
+	// resolves to the function itself inside its body.
+	FUNCTION_SCOPE Symbol_scope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope Symbol_scope
+	Index int
+}
+
+// Symbol_table maps the names of one scope to where their values live.
+// Each function body has its own table, enclosed by the table of the scope
+// the function is defined in.
+type Symbol_table struct {
+	Outer *Symbol_table
+
+	store           map[string]Symbol
+	Num_definitions int
+	// Free_symbols are the symbols of enclosing functions this scope
+	// captures, in the order of their FREE_SCOPE indexes.
+	Free_symbols []Symbol
+}
+
+func New_symbol_table() *Symbol_table {
+	return &Symbol_table{store: make(map[string]Symbol)}
+}
+
+func New_enclosed_symbol_table(outer *Symbol_table) *Symbol_table {
+	s := New_symbol_table()
+	s.Outer = outer
+	return s
+}
+
+// Define binds name in this scope, as a global at the top level and as a
+// local inside functions.
+func (s *Symbol_table) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.Num_definitions}
+	if s.Outer == nil {
+		symbol.Scope = GLOBAL_SCOPE
+	} else {
+		symbol.Scope = LOCAL_SCOPE
+	}
+
+	s.store[name] = symbol
+	s.Num_definitions++
+	return symbol
+}
+
+func (s *Symbol_table) Define_function_name(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: FUNCTION_SCOPE}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Resolve looks name up in this scope and the enclosing ones. A local of
+// an enclosing function becomes a free symbol of this scope.
+func (s *Symbol_table) Resolve(name string) (Symbol, bool) {
+	if symbol, ok := s.store[name]; ok {
+		return symbol, true
+	}
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	symbol, ok := s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GLOBAL_SCOPE {
+		return symbol, ok
+	}
+	return s.define_free(symbol), true
+}
+
+func (s *Symbol_table) define_free(original Symbol) Symbol {
+	s.Free_symbols = append(s.Free_symbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FREE_SCOPE, Index: len(s.Free_symbols) - 1}
+	s.store[original.Name] = symbol
+	return symbol
+}
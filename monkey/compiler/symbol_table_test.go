@@ -0,0 +1,63 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := New_symbol_table()
+	a := global.Define("a")
+	global.Define("b")
+
+	local := New_enclosed_symbol_table(global)
+	local.Define("c")
+
+	nested := New_enclosed_symbol_table(local)
+	nested.Define("d")
+
+	if a != (Symbol{Name: "a", Scope: GLOBAL_SCOPE, Index: 0}) {
+		t.Errorf("a wrong. got=%+v", a)
+	}
+
+	expected := []Symbol{
+		{Name: "a", Scope: GLOBAL_SCOPE, Index: 0},
+		{Name: "b", Scope: GLOBAL_SCOPE, Index: 1},
+		{Name: "c", Scope: FREE_SCOPE, Index: 0},
+		{Name: "d", Scope: LOCAL_SCOPE, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := nested.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(nested.Free_symbols) != 1 || nested.Free_symbols[0] != (Symbol{Name: "c", Scope: LOCAL_SCOPE, Index: 0}) {
+		t.Errorf("wrong free symbols. got=%+v", nested.Free_symbols)
+	}
+	if symbol, ok := local.Resolve("c"); !ok || symbol.Scope != LOCAL_SCOPE {
+		t.Errorf("c is no longer local to its own scope. got=%+v", symbol)
+	}
+	if _, ok := nested.Resolve("e"); ok {
+		t.Errorf("undefined name e resolved")
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	global := New_symbol_table()
+	inner := New_enclosed_symbol_table(global)
+	inner.Define_function_name("f")
+
+	expected := Symbol{Name: "f", Scope: FUNCTION_SCOPE, Index: 0}
+	if result, ok := inner.Resolve("f"); !ok || result != expected {
+		t.Errorf("f wrong. expected=%+v, got=%+v", expected, result)
+	}
+
+	// A parameter or local with the same name shadows the function.
+	inner.Define("f")
+	if result, _ := inner.Resolve("f"); result.Scope != LOCAL_SCOPE {
+		t.Errorf("f not shadowed. got=%+v", result)
+	}
+}
@@ -0,0 +1,169 @@
+package cst
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+type Trivia_kind int
+
+const (
+	WHITESPACE Trivia_kind = iota
+	COMMENT
+)
+
+// Trivia is source text the parser skips: whitespace and comments.
+type Trivia struct {
+	Kind Trivia_kind
+	Text string
+}
+
+// Token is a lexer token together with the exact source text it was read
+// from and the trivia that precedes it.
+type Token struct {
+	token.Token
+	Leading []Trivia
+	Text    string
+	Start   int
+	End     int
+}
+
+// Tree is the concrete syntax of a file: every token with its trivia, plus
+// the AST parsed from those tokens. Each AST node maps to the range of
+// tokens it covers, so a node's original text can be recovered or edited.
+type Tree struct {
+	Program *ast.Program
+	Tokens  []Token
+	Errors  []string
+
+	// Rest holds input the lexer never reached, e.g. after a NUL byte.
+	Rest string
+
+	spans map[ast.Node]parser.Span
+}
+
+func Parse(input string) *Tree {
+	tree := &Tree{}
+
+	fset := token.New_file_set()
+	l := lexer.New(input, lexer.With_file(fset.Add_file("", len(input))))
+	var tokens []token.Token
+	prev := 0
+	for {
+		tok := l.NextToken()
+		start, end := l.Span()
+
+		tree.Tokens = append(tree.Tokens, Token{
+			Token:   tok,
+			Leading: split_trivia(input[prev:start]),
+			Text:    input[start:end],
+			Start:   start,
+			End:     end,
+		})
+		tokens = append(tokens, tok)
+		prev = end
+
+		if tok.Type == token.EOF {
+			tree.Rest = input[end:]
+			break
+		}
+	}
+
+	// The parser reads the tokens collected above rather than lexing the
+	// input again, so that its spans index exactly those tokens.
+	p := parser.New(token.From_slice(tokens), parser.With_file_set(fset))
+	p.Record_spans()
+	tree.Program = p.Parse_program()
+	tree.Errors = p.Errors()
+	tree.spans = p.Spans()
+
+	return tree
+}
+
+// Span returns the tokens node was parsed from.
+func (t *Tree) Span(node ast.Node) (parser.Span, bool) {
+	span, ok := t.spans[node]
+	return span, ok
+}
+
+// Text returns the current source text of node, including any trivia
+// between its first and last token.
+func (t *Tree) Text(node ast.Node) string {
+	span, ok := t.spans[node]
+	if !ok {
+		return ""
+	}
+
+	var out bytes.Buffer
+	for i := span.First; i <= span.Last; i++ {
+		if i > span.First {
+			write_trivia(&out, t.Tokens[i].Leading)
+		}
+		out.WriteString(t.Tokens[i].Text)
+	}
+	return out.String()
+}
+
+// Replace substitutes text for the source of node, keeping the trivia
+// before and after it intact. It reports whether node belongs to the tree.
+func (t *Tree) Replace(node ast.Node, text string) bool {
+	span, ok := t.spans[node]
+	if !ok || span.Last < span.First {
+		return false
+	}
+
+	t.Tokens[span.First].Text = text
+	for i := span.First + 1; i <= span.Last; i++ {
+		t.Tokens[i].Leading = nil
+		t.Tokens[i].Text = ""
+	}
+	return true
+}
+
+// String prints the tree. An unedited tree reproduces its input exactly.
+func (t *Tree) String() string {
+	var out bytes.Buffer
+
+	for _, tok := range t.Tokens {
+		write_trivia(&out, tok.Leading)
+		out.WriteString(tok.Text)
+	}
+	out.WriteString(t.Rest)
+
+	return out.String()
+}
+
+func write_trivia(out *bytes.Buffer, trivia []Trivia) {
+	for _, tr := range trivia {
+		out.WriteString(tr.Text)
+	}
+}
+
+func split_trivia(text string) []Trivia {
+	var trivia []Trivia
+
+	for len(text) > 0 {
+		// A shebang line can only be trivia at the start of the input.
+		if strings.HasPrefix(text, "//") || strings.HasPrefix(text, "#!") {
+			end := strings.IndexByte(text, '\n')
+			if end < 0 {
+				end = len(text)
+			}
+			trivia = append(trivia, Trivia{Kind: COMMENT, Text: text[:end]})
+			text = text[end:]
+			continue
+		}
+
+		end := strings.Index(text, "//")
+		if end < 0 {
+			end = len(text)
+		}
+		trivia = append(trivia, Trivia{Kind: WHITESPACE, Text: text[:end]})
+		text = text[end:]
+	}
+	return trivia
+}
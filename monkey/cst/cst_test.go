@@ -0,0 +1,119 @@
+package cst
+
+import (
+	"monkey/ast"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t",
+		"let x = 5;",
+		"let   add = fn(a,b){\n\treturn a+b;   // sum\n};\n",
+		"// header comment\n\nlet y = (1 + (2));;\n// trailing",
+		"if (x<y) { x } else { y }\r\n",
+		"a @ b $ ü",
+		"let x = 1;\x00 anything after a NUL",
+		"add(1, 2 * 3, 4 + 5)",
+		"let = ;)",
+		"#!/usr/bin/env monkey\nlet x = 1\n",
+	}
+
+	for _, input := range inputs {
+		tree := Parse(input)
+		if tree.String() != input {
+			t.Errorf("round trip failed.\nexpected=%q\ngot=%q", input, tree.String())
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tree := Parse("let x = f(\n\t1\n)\nlet = 2")
+
+	expected := "4:5: expected next token to be IDENT, got = instead"
+	if len(tree.Errors) == 0 || tree.Errors[0] != expected {
+		t.Fatalf("errors wrong. expected=%q, got=%q", expected, tree.Errors)
+	}
+
+	if text := tree.Text(tree.Program.Statements[0]); text != "let x = f(\n\t1\n)" {
+		t.Errorf("text of the first statement wrong. got=%q", text)
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	tree := Parse("let x = 5; // five\n  x")
+
+	ident := tree.Tokens[5]
+	if ident.Text != "x" {
+		t.Fatalf("Tokens[5] wrong. got=%q", ident.Text)
+	}
+
+	expected := []Trivia{
+		{WHITESPACE, " "},
+		{COMMENT, "// five"},
+		{WHITESPACE, "\n  "},
+	}
+	if len(ident.Leading) != len(expected) {
+		t.Fatalf("wrong trivia count. want=%d, got=%d (%+v)", len(expected), len(ident.Leading), ident.Leading)
+	}
+	for i, tr := range expected {
+		if ident.Leading[i] != tr {
+			t.Errorf("trivia %d wrong. want=%+v, got=%+v", i, tr, ident.Leading[i])
+		}
+	}
+}
+
+func TestNodeText(t *testing.T) {
+	input := "let total = add( 1,  2 ) * (3+4) ;\nfn(a, b) { a }"
+	tree := Parse(input)
+	if len(tree.Errors) != 0 {
+		t.Fatalf("parser errors: %v", tree.Errors)
+	}
+
+	let := tree.Program.Statements[0].(*ast.Let_statement)
+	infix := let.Value.(*ast.Infix_expression)
+	call := infix.Left.(*ast.Call_expression)
+	fn := tree.Program.Statements[1].(*ast.Expression_statement).Expression.(*ast.Function_literal)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{tree.Program, input},
+		{let, "let total = add( 1,  2 ) * (3+4) ;"},
+		{let.Name, "total"},
+		{infix, "add( 1,  2 ) * (3+4)"},
+		{call, "add( 1,  2 )"},
+		{call.Arguments[1], "2"},
+		{infix.Right, "(3+4)"},
+		{fn, "fn(a, b) { a }"},
+		{fn.Parameters[1], "b"},
+		{fn.Body, "{ a }"},
+	}
+
+	for _, tt := range tests {
+		if got := tree.Text(tt.node); got != tt.expected {
+			t.Errorf("Text(%s) wrong. expected=%q, got=%q", tt.node.String(), tt.expected, got)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	input := "// compute\nlet x = a  +  b; // keep me\nx"
+	tree := Parse(input)
+
+	let := tree.Program.Statements[0].(*ast.Let_statement)
+	if !tree.Replace(let.Value, "add(a, b)") {
+		t.Fatalf("Replace returned false")
+	}
+
+	expected := "// compute\nlet x = add(a, b); // keep me\nx"
+	if tree.String() != expected {
+		t.Errorf("Replace wrong.\nexpected=%q\ngot=%q", expected, tree.String())
+	}
+
+	if tree.Replace(&ast.Identifier{Value: "other"}, "y") {
+		t.Errorf("Replace accepted a node from another tree")
+	}
+}
@@ -0,0 +1,3 @@
+module monkey/cst
+
+go 1.24.1
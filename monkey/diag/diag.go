@@ -0,0 +1,209 @@
+package diag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/highlight"
+	"monkey/parser"
+	"monkey/term"
+	"monkey/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const TAB_WIDTH = 4
+
+const (
+	bold      = "\x1b[1m"
+	bold_red  = "\x1b[1;31m"
+	bold_blue = "\x1b[1;34m"
+	reset     = "\x1b[0m"
+)
+
+// Printer renders parse errors in the style of rustc, quoting the source
+// lines they point at and underlining the offending tokens:
+//
+//	error: expected `)`, found `let`
+//	 --> main.mk:2:1
+//	  |
+//	1 | let total = add(1, 2
+//	  |                - call started here
+//	2 | let next = 3;
+//	  | ^^^ expected `)` to close the call
+//	  = help: insert `)`
+type Printer struct {
+	file  *token.File
+	lines []string
+
+	// Color enables ANSI colors, see Use_color. The quoted source is then
+	// syntax highlighted.
+	Color bool
+}
+
+// New returns a Printer for errors in source, whose positions belong to
+// file.
+func New(file *token.File, source string) *Printer {
+	return &Printer{file: file, lines: strings.Split(source, "\n")}
+}
+
+// Use_color reports whether output to f should be colored: f must be a
+// terminal and neither NO_COLOR nor TERM=dumb set.
+func Use_color(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.Is_terminal(int(f.Fd()))
+}
+
+// Fprint writes every error in errs, separated by blank lines.
+func (pr *Printer) Fprint(w io.Writer, errs []*parser.ParseError) {
+	for i, err := range errs {
+		if i > 0 {
+			io.WriteString(w, "\n")
+		}
+		io.WriteString(w, pr.Render(err))
+	}
+}
+
+type label struct {
+	line    int
+	column  int
+	width   int
+	msg     string
+	primary bool
+}
+
+// Render formats a single error. Errors without a position in the
+// printer's file are rendered as a single line.
+func (pr *Printer) Render(err *parser.ParseError) string {
+	var out bytes.Buffer
+
+	out.WriteString(pr.paint(bold_red, "error"))
+	out.WriteString(pr.paint(bold, ": "+err.Summary()))
+	out.WriteString("\n")
+
+	primary, ok := pr.label(err.Pos, err.End, err.Label, true)
+	if !ok {
+		pr.write_help(&out, err, " ")
+		return out.String()
+	}
+	labels := []label{primary}
+	for _, related := range err.Related {
+		if l, ok := pr.label(related.Pos, related.End, related.Msg, false); ok {
+			labels = append(labels, l)
+		}
+	}
+
+	// Lines top to bottom; on a line, the rightmost label first, so that
+	// each marker row only crosses columns no other label has used yet.
+	sort.SliceStable(labels, func(i, j int) bool {
+		if labels[i].line != labels[j].line {
+			return labels[i].line < labels[j].line
+		}
+		return labels[i].column > labels[j].column
+	})
+
+	gutter := len(strconv.Itoa(labels[len(labels)-1].line))
+	margin := strings.Repeat(" ", gutter)
+	bar := pr.paint(bold_blue, margin+" |")
+
+	fmt.Fprintf(&out, "%s%s %s\n", margin, pr.paint(bold_blue, "-->"), pr.file.Position(err.Pos))
+	out.WriteString(bar + "\n")
+
+	previous := 0
+	for _, l := range labels {
+		if l.line != previous {
+			if previous != 0 && l.line > previous+1 {
+				out.WriteString(pr.paint(bold_blue, "...") + "\n")
+			}
+			number := fmt.Sprintf("%*d |", gutter, l.line)
+			fmt.Fprintf(&out, "%s %s\n", pr.paint(bold_blue, number), pr.quote(l.line))
+			previous = l.line
+		}
+
+		marker, color := "-", bold_blue
+		if l.primary {
+			marker, color = "^", bold_red
+		}
+		annotation := strings.Repeat(marker, l.width)
+		if l.msg != "" {
+			annotation += " " + l.msg
+		}
+		fmt.Fprintf(&out, "%s %s%s\n", bar, strings.Repeat(" ", l.column), pr.paint(color, annotation))
+	}
+	pr.write_help(&out, err, margin+" ")
+
+	return out.String()
+}
+
+func (pr *Printer) write_help(out *bytes.Buffer, err *parser.ParseError, margin string) {
+	if err.Help != "" {
+		fmt.Fprintf(out, "%s%s %s: %s\n", margin, pr.paint(bold_blue, "="), pr.paint(bold, "help"), err.Help)
+	}
+}
+
+// label resolves the span from pos to end to a line and to the visual
+// column and width of its part on that line.
+func (pr *Printer) label(pos, end token.Pos, msg string, primary bool) (label, bool) {
+	if pr.file == nil || !pos.Is_valid() {
+		return label{}, false
+	}
+	position := pr.file.Position(pos)
+	if !position.Is_valid() || position.Line > len(pr.lines) {
+		return label{}, false
+	}
+
+	text := pr.line(position.Line)
+	from := min(position.Column-1, len(text))
+	to := min(from+max(int(end-pos), 0), len(text))
+
+	return label{
+		line:    position.Line,
+		column:  visual_width(text[:from]),
+		width:   max(visual_width(text[from:to]), 1),
+		msg:     msg,
+		primary: primary,
+	}, true
+}
+
+func (pr *Printer) line(n int) string {
+	return strings.TrimSuffix(pr.lines[n-1], "\r")
+}
+
+// quote returns line n as it is shown in a snippet, syntax highlighted
+// when colors are on.
+func (pr *Printer) quote(n int) string {
+	if !pr.Color {
+		return expand_tabs(pr.line(n))
+	}
+	return expand_tabs(highlight.ANSI(pr.line(n)))
+}
+
+func (pr *Printer) paint(color, s string) string {
+	if !pr.Color {
+		return s
+	}
+	return color + s + reset
+}
+
+func visual_width(s string) int {
+	width := 0
+	for _, r := range s {
+		if r == '\t' {
+			width += TAB_WIDTH
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+func expand_tabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	return strings.ReplaceAll(s, "\t", strings.Repeat(" ", TAB_WIDTH))
+}
@@ -0,0 +1,144 @@
+package diag
+
+import (
+	"monkey/highlight"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"os"
+	"strings"
+	"testing"
+)
+
+func parse_errors(source string) (*token.File, []*parser.ParseError) {
+	fset := token.New_file_set()
+	file := fset.Add_file("main.mk", len(source))
+	p := parser.New(lexer.New(source, lexer.With_file(file)))
+	p.Parse_program()
+	return file, p.Parse_errors()
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{
+			"let total = add(1, 2\nlet next = 3;",
+			"error: expected `)`, found `let`\n" +
+				" --> main.mk:2:1\n" +
+				"  |\n" +
+				"1 | let total = add(1, 2\n" +
+				"  |                - call started here\n" +
+				"2 | let next = 3;\n" +
+				"  | ^^^ expected `)` to close the call\n" +
+				"  = help: insert `)`\n",
+		},
+		{
+			"let f = fn(x) {\n\tx + 1\n\n",
+			"error: expected `}`, found end of input\n" +
+				" --> main.mk:4:1\n" +
+				"  |\n" +
+				"1 | let f = fn(x) {\n" +
+				"  |               - block started here\n" +
+				"...\n" +
+				"4 | \n" +
+				"  | ^ expected `}` to close the block\n" +
+				"  = help: insert `}`\n",
+		},
+		{
+			"if (a) {\n\tlet x = 99999999999999999999\n}",
+			"error: could not parse \"99999999999999999999\" as integer\n" +
+				" --> main.mk:2:10\n" +
+				"  |\n" +
+				"2 |     let x = 99999999999999999999\n" +
+				"  |             ^^^^^^^^^^^^^^^^^^^^ does not fit in a 64-bit integer\n",
+		},
+		{
+			"let = 5",
+			"error: expected an identifier, found `=`\n" +
+				" --> main.mk:1:5\n" +
+				"  |\n" +
+				"1 | let = 5\n" +
+				"  |     ^\n",
+		},
+		{
+			"let x = 1\nretrun x",
+			"error: unexpected identifier x after retrun, did you mean return?\n" +
+				" --> main.mk:2:1\n" +
+				"  |\n" +
+				"2 | retrun x\n" +
+				"  | ^^^^^^ `retrun` is not a keyword\n" +
+				"  = help: replace `retrun` with `return`\n",
+		},
+	}
+
+	for _, tt := range tests {
+		file, errs := parse_errors(tt.source)
+		if len(errs) == 0 {
+			t.Fatalf("no errors for %q", tt.source)
+		}
+
+		got := New(file, tt.source).Render(errs[0])
+		if got != tt.expected {
+			t.Errorf("Render wrong for %q.\nexpected:\n%s\ngot:\n%s", tt.source, tt.expected, got)
+		}
+	}
+}
+
+func TestRenderWithoutPosition(t *testing.T) {
+	err := &parser.ParseError{Msg: "something went wrong", Help: "try again"}
+
+	got := New(nil, "").Render(err)
+	if got != "error: something went wrong\n = help: try again\n" {
+		t.Errorf("Render wrong. got=%q", got)
+	}
+}
+
+func TestColor(t *testing.T) {
+	source := "f(1"
+	file, errs := parse_errors(source)
+
+	printer := New(file, source)
+	printer.Color = true
+
+	got := printer.Render(errs[0])
+	for _, part := range []string{
+		bold_red + "error" + reset,
+		bold_red + "^ expected `)` to close the call" + reset,
+		bold_blue + "1 |" + reset + " f(" + highlight.ANSI("1") + "\n",
+	} {
+		if !strings.Contains(got, part) {
+			t.Errorf("colored output has no %q:\n%q", part, got)
+		}
+	}
+
+	printer.Color = false
+	if plain := printer.Render(errs[0]); strings.Contains(plain, "\x1b") {
+		t.Errorf("uncolored output has escape codes: %q", plain)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer null.Close()
+
+	if Use_color(null) {
+		t.Errorf("colored output to %s", os.DevNull)
+	}
+}
+
+func TestFprint(t *testing.T) {
+	source := "let = 5"
+	file, errs := parse_errors(source)
+
+	var out strings.Builder
+	New(file, source).Fprint(&out, errs)
+
+	if strings.Count(out.String(), "error: ") != len(errs) || !strings.Contains(out.String(), "^\n\nerror: ") {
+		t.Errorf("Fprint wrong. got=%q", out.String())
+	}
+}
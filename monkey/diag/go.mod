@@ -0,0 +1,3 @@
+module monkey/diag
+
+go 1.24.1
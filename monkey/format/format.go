@@ -0,0 +1,134 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"monkey/ast"
+	"monkey/cst"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+// Source formats a program. Like gofmt it keeps the line structure and the
+// comments of src, indents with one tab per open brace or parenthesis,
+// keeps at most one blank line in a row and normalizes the spacing within
+// lines. Programs with syntax errors are not formatted.
+func Source(src string) (string, error) {
+	tree := cst.Parse(src)
+	if len(tree.Errors) != 0 {
+		return "", errors.New(strings.Join(tree.Errors, "\n"))
+	}
+	if tree.Rest != "" {
+		return "", errors.New("input contains a NUL byte")
+	}
+
+	f := &formatter{}
+	for _, tok := range tree.Tokens {
+		f.token(tok)
+	}
+	out := f.out.String()
+
+	// Formatting must not change what the program means.
+	p := parser.New(lexer.New(out))
+	if !ast.Equal(tree.Program, p.Parse_program(), ast.Ignore_positions()) || len(p.Errors()) != 0 {
+		return "", errors.New("formatting changed the program")
+	}
+	return out, nil
+}
+
+type formatter struct {
+	out   bytes.Buffer
+	depth int
+	// newlines counts the line breaks since the last token or comment.
+	newlines int
+	prev     token.Token
+	// prefix is set when prev is a prefix operator.
+	prefix bool
+}
+
+func (f *formatter) token(tok cst.Token) {
+	for _, trivia := range tok.Leading {
+		if trivia.Kind == cst.WHITESPACE {
+			f.newlines += strings.Count(trivia.Text, "\n")
+			continue
+		}
+		if f.out.Len() == 0 || f.newlines > 0 {
+			f.line_break(f.depth)
+		} else {
+			f.out.WriteString(" ")
+		}
+		f.out.WriteString(strings.TrimRight(trivia.Text, " \t\r"))
+	}
+
+	switch {
+	case tok.Type == token.EOF:
+		if f.out.Len() > 0 {
+			f.out.WriteString("\n")
+		}
+		return
+	case tok.Type == token.SEMICOLON && tok.Text == "":
+		// An inserted semicolon: the line break follows as trivia.
+		f.prev, f.prefix = tok.Token, false
+		return
+	}
+
+	closer := tok.Type == token.RBRACE || tok.Type == token.RPAREN
+	if closer {
+		f.depth = max(f.depth-1, 0)
+	}
+
+	if f.out.Len() > 0 && f.newlines > 0 {
+		f.line_break(f.depth)
+	} else if f.out.Len() > 0 && f.space_before(tok.Token) {
+		f.out.WriteString(" ")
+	}
+	f.out.WriteString(tok.Text)
+	f.newlines = 0
+
+	if tok.Type == token.LBRACE || tok.Type == token.LPAREN {
+		f.depth++
+	}
+	f.prefix = tok.Type == token.BANG || tok.Type == token.MINUS && !ends_operand(f.prev.Type)
+	f.prev = tok.Token
+}
+
+// line_break starts a new line indented by depth, after a blank line if
+// there was at least one.
+func (f *formatter) line_break(depth int) {
+	if f.out.Len() > 0 {
+		f.out.WriteString("\n")
+		if f.newlines > 1 {
+			f.out.WriteString("\n")
+		}
+	}
+	f.out.WriteString(strings.Repeat("\t", depth))
+	f.newlines = 0
+}
+
+func (f *formatter) space_before(tok token.Token) bool {
+	switch {
+	case f.prefix, f.prev.Type == token.LPAREN:
+		return false
+	case tok.Type == token.COMMA, tok.Type == token.SEMICOLON, tok.Type == token.RPAREN:
+		return false
+	case tok.Type == token.LPAREN:
+		// Calls and parameter lists hug what precedes them.
+		switch f.prev.Type {
+		case token.IDENT, token.RPAREN, token.RBRACE, token.FUNCTION:
+			return false
+		}
+	}
+	return true
+}
+
+// ends_operand reports whether a token of type t can end an operand, so
+// that a minus after it is an infix operator.
+func ends_operand(t token.TokenType) bool {
+	switch t {
+	case token.IDENT, token.INT, token.TRUE, token.FALSE, token.RPAREN, token.RBRACE:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5;", "let x = 5;\n"},
+		{"let   add = fn(a,b){\nreturn a+b;   // sum\n};\n",
+			"let add = fn(a, b) {\n\treturn a + b; // sum\n};\n"},
+		{"\n\n// header\n\n\n\nlet y = ( 1 + -2 )\n\n\n// trailing   \n",
+			"// header\n\nlet y = (1 + -2)\n\n// trailing\n"},
+		{"if(x<y){x}else{y}", "if (x < y) { x } else { y }\n"},
+		{"add( 1,\n2 )", "add(1,\n\t2)\n"},
+		{"let f = fn ( x ) {\n  if (!x) {\n      // nothing\n  return -x\n  }\n  x - 1\n}\nf (1)",
+			"let f = fn(x) {\n\tif (!x) {\n\t\t// nothing\n\t\treturn -x\n\t}\n\tx - 1\n}\nf(1)\n"},
+		{"fn(x){x}(5)", "fn(x) { x }(5)\n"},
+		{"a\n-b", "a\n-b\n"},
+		{"a - - b * !c", "a - -b * !c\n"},
+		{"#!/usr/bin/env monkey\nputs(1)\n", "#!/usr/bin/env monkey\nputs(1)\n"},
+		{"", ""},
+		{"   \n", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("input %q: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("input %q: wrong output.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+
+		again, err := Source(got)
+		if err != nil || again != got {
+			t.Errorf("input %q: formatting is not idempotent. got=%q, %v", tt.input, again, err)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		error string
+	}{
+		{"let = 5", "expected next token to be IDENT"},
+		{"let x = 1;\x00 rest", "NUL byte"},
+	}
+
+	for _, tt := range tests {
+		_, err := Source(tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("input %q: expected error containing %q, got %v", tt.input, tt.error, err)
+		}
+	}
+}
+
+func TestParserCorpus(t *testing.T) {
+	files, err := filepath.Glob("../parser/testdata/*.monkey")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no corpus: %v", err)
+	}
+
+	for _, file := range files {
+		if strings.HasPrefix(filepath.Base(file), "error_") {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		once, err := Source(string(source))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if twice, err := Source(once); err != nil || twice != once {
+			t.Errorf("%s: formatting is not idempotent.\nonce=%q\ntwice=%q", file, once, twice)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module monkey/format
+
+go 1.24.1
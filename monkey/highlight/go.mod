@@ -0,0 +1,3 @@
+module monkey/highlight
+
+go 1.24.1
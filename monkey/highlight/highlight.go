@@ -0,0 +1,192 @@
+package highlight
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"monkey/lexer"
+	"monkey/token"
+	"strings"
+)
+
+type Class int
+
+const (
+	// TEXT is whitespace and input the lexer never reached.
+	TEXT Class = iota
+	KEYWORD
+	LITERAL
+	OPERATOR
+	PUNCTUATION
+	IDENTIFIER
+	COMMENT
+	ILLEGAL
+)
+
+var class_names = [...]string{
+	TEXT:        "text",
+	KEYWORD:     "keyword",
+	LITERAL:     "literal",
+	OPERATOR:    "operator",
+	PUNCTUATION: "punctuation",
+	IDENTIFIER:  "identifier",
+	COMMENT:     "comment",
+	ILLEGAL:     "illegal",
+}
+
+// String returns the name of the class, which is also its CSS class.
+func (c Class) String() string {
+	if c >= 0 && int(c) < len(class_names) {
+		return class_names[c]
+	}
+	return fmt.Sprintf("Class(%d)", int(c))
+}
+
+// Span is a run of source text of one class.
+type Span struct {
+	Class Class
+	Text  string
+	Start int
+	End   int
+}
+
+// Classify returns the class of a token. Token types registered by
+// extensions are keywords when their literal is a word and operators
+// otherwise.
+func Classify(tok token.Token) Class {
+	switch tok.Type {
+	case token.IDENT:
+		return IDENTIFIER
+	case token.INT, token.TRUE, token.FALSE:
+		return LITERAL
+	case token.FUNCTION, token.LET, token.IF, token.ELSE, token.RETURN:
+		return KEYWORD
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.SLASH,
+		token.ASTERISK, token.LT, token.GT, token.EQ, token.NOT_EQ:
+		return OPERATOR
+	case token.COMMA, token.SEMICOLON, token.LPAREN, token.RPAREN,
+		token.LBRACE, token.RBRACE:
+		return PUNCTUATION
+	case token.ILLEGAL:
+		return ILLEGAL
+	}
+
+	if tok.Literal != "" && strings.TrimLeft(tok.Literal, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_") == "" {
+		return KEYWORD
+	}
+	return OPERATOR
+}
+
+// Spans splits input into classified spans which, joined, reproduce it
+// exactly. The options are passed to the lexer, so that extensions'
+// tokens are recognized.
+func Spans(input string, opts ...lexer.Option) []Span {
+	var spans []Span
+	add := func(class Class, start, end int) {
+		if start >= end {
+			return
+		}
+		// The lexer reads illegal input a byte at a time, so join the
+		// bytes back into runes.
+		if n := len(spans); class == ILLEGAL && n > 0 && spans[n-1].Class == ILLEGAL && spans[n-1].End == start {
+			spans[n-1].Text, spans[n-1].End = input[spans[n-1].Start:end], end
+			return
+		}
+		spans = append(spans, Span{class, input[start:end], start, end})
+	}
+
+	l := lexer.New(input, opts...)
+	prev := 0
+	for {
+		tok := l.NextToken()
+		start, end := l.Span()
+
+		// Between tokens there is only whitespace and comments, and at the
+		// start a shebang line.
+		for prev < start {
+			gap := input[prev:start]
+			if strings.HasPrefix(gap, "//") || prev == 0 && strings.HasPrefix(gap, "#!") {
+				n := strings.IndexByte(gap, '\n')
+				if n < 0 {
+					n = len(gap)
+				}
+				add(COMMENT, prev, prev+n)
+				prev += n
+				continue
+			}
+			n := strings.Index(gap, "//")
+			if n < 0 {
+				n = len(gap)
+			}
+			add(TEXT, prev, prev+n)
+			prev += n
+		}
+
+		if tok.Type == token.EOF {
+			add(TEXT, end, len(input))
+			return spans
+		}
+		add(Classify(tok), start, end)
+		prev = max(prev, end)
+	}
+}
+
+const reset = "\x1b[0m"
+
+// ANSI_COLORS are the escape sequences ANSI starts each class with.
+var ANSI_COLORS = map[Class]string{
+	KEYWORD:  "\x1b[35m",
+	LITERAL:  "\x1b[36m",
+	OPERATOR: "\x1b[33m",
+	COMMENT:  "\x1b[90m",
+	ILLEGAL:  "\x1b[31m",
+}
+
+// ANSI returns input colored for a terminal.
+func ANSI(input string, opts ...lexer.Option) string {
+	var out bytes.Buffer
+	for _, span := range Spans(input, opts...) {
+		if color, ok := ANSI_COLORS[span.Class]; ok {
+			out.WriteString(color + span.Text + reset)
+		} else {
+			out.WriteString(span.Text)
+		}
+	}
+	return out.String()
+}
+
+// HTML returns input as a <pre class="monkey"> element, with a <span> for
+// each token carrying its class name, e.g. <span class="keyword">let</span>.
+func HTML(input string, opts ...lexer.Option) string {
+	var out bytes.Buffer
+	out.WriteString(`<pre class="monkey">`)
+	for _, span := range Spans(input, opts...) {
+		if span.Class == TEXT {
+			out.WriteString(html.EscapeString(span.Text))
+			continue
+		}
+		fmt.Fprintf(&out, `<span class="%s">%s</span>`, span.Class, html.EscapeString(span.Text))
+	}
+	out.WriteString("</pre>")
+	return out.String()
+}
+
+// STYLESHEET styles the classes HTML uses.
+const STYLESHEET = `pre.monkey { background: #fafafa; padding: 1em; tab-size: 4; }
+pre.monkey .keyword { color: #a626a4; font-weight: bold; }
+pre.monkey .literal { color: #0184bc; }
+pre.monkey .operator { color: #986801; }
+pre.monkey .punctuation { color: #383a42; }
+pre.monkey .identifier { color: #383a42; }
+pre.monkey .comment { color: #a0a1a7; font-style: italic; }
+pre.monkey .illegal { color: #e45649; text-decoration: underline wavy; }
+`
+
+// Document returns a standalone HTML page showing input, styled with
+// STYLESHEET.
+func Document(title string, input string, opts ...lexer.Option) string {
+	return "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n" +
+		"<title>" + html.EscapeString(title) + "</title>\n" +
+		"<style>\n" + STYLESHEET + "</style>\n</head>\n<body>\n" +
+		HTML(input, opts...) + "\n</body>\n</html>\n"
+}
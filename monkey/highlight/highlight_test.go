@@ -0,0 +1,110 @@
+package highlight
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	input := "let x = fn(a) { a * 2 }; // double\nif (true) { x(1) }"
+	expected := []struct {
+		class Class
+		text  string
+	}{
+		{KEYWORD, "let"}, {TEXT, " "}, {IDENTIFIER, "x"}, {TEXT, " "}, {OPERATOR, "="}, {TEXT, " "},
+		{KEYWORD, "fn"}, {PUNCTUATION, "("}, {IDENTIFIER, "a"}, {PUNCTUATION, ")"}, {TEXT, " "},
+		{PUNCTUATION, "{"}, {TEXT, " "}, {IDENTIFIER, "a"}, {TEXT, " "}, {OPERATOR, "*"}, {TEXT, " "},
+		{LITERAL, "2"}, {TEXT, " "}, {PUNCTUATION, "}"}, {PUNCTUATION, ";"}, {TEXT, " "},
+		{COMMENT, "// double"}, {TEXT, "\n"},
+		{KEYWORD, "if"}, {TEXT, " "}, {PUNCTUATION, "("}, {LITERAL, "true"}, {PUNCTUATION, ")"}, {TEXT, " "},
+		{PUNCTUATION, "{"}, {TEXT, " "}, {IDENTIFIER, "x"}, {PUNCTUATION, "("}, {LITERAL, "1"},
+		{PUNCTUATION, ")"}, {TEXT, " "}, {PUNCTUATION, "}"},
+	}
+
+	spans := Spans(input)
+	if len(spans) != len(expected) {
+		t.Fatalf("wrong number of spans. expected=%d, got=%d: %v", len(expected), len(spans), spans)
+	}
+	for i, span := range spans {
+		if span.Class != expected[i].class || span.Text != expected[i].text {
+			t.Errorf("spans[%d] wrong. expected=%s %q, got=%s %q", i, expected[i].class, expected[i].text, span.Class, span.Text)
+		}
+		if input[span.Start:span.End] != span.Text {
+			t.Errorf("spans[%d] has wrong offsets %d:%d for %q", i, span.Start, span.End, span.Text)
+		}
+	}
+}
+
+func TestSpansReproduceInput(t *testing.T) {
+	inputs := []string{
+		"",
+		"// only a comment",
+		"x // a\n// b\n\n  y",
+		"let é = 1 @ 2",
+		"a\x00 after a NUL byte",
+		"1 // trailing\n",
+	}
+
+	for _, input := range inputs {
+		var joined strings.Builder
+		for _, span := range Spans(input) {
+			joined.WriteString(span.Text)
+		}
+		if joined.String() != input {
+			t.Errorf("spans do not reproduce %q. got=%q", input, joined.String())
+		}
+	}
+
+	spans := Spans("#!/usr/bin/env monkey\nx")
+	if len(spans) != 3 || spans[0].Class != COMMENT || spans[0].Text != "#!/usr/bin/env monkey" {
+		t.Errorf("shebang line not a comment. got=%v", spans)
+	}
+
+	spans = Spans("é")
+	if len(spans) != 1 || spans[0].Class != ILLEGAL || spans[0].Text != "é" {
+		t.Errorf("illegal rune split. got=%v", spans)
+	}
+}
+
+func TestExtensionTokens(t *testing.T) {
+	pipe := token.Register("|>")
+	unless := token.Register("UNLESS")
+
+	spans := Spans("x |> f unless y", lexer.With_operator("|>", pipe), lexer.With_keyword("unless", unless))
+	classes := []Class{IDENTIFIER, TEXT, OPERATOR, TEXT, IDENTIFIER, TEXT, KEYWORD, TEXT, IDENTIFIER}
+	if len(spans) != len(classes) {
+		t.Fatalf("wrong number of spans. got=%v", spans)
+	}
+	for i, span := range spans {
+		if span.Class != classes[i] {
+			t.Errorf("spans[%d] %q wrong. expected=%s, got=%s", i, span.Text, classes[i], span.Class)
+		}
+	}
+}
+
+func TestANSI(t *testing.T) {
+	expected := "\x1b[35mlet\x1b[0m x \x1b[33m=\x1b[0m \x1b[36m5\x1b[0m; \x1b[90m// five\x1b[0m"
+
+	if got := ANSI("let x = 5; // five"); got != expected {
+		t.Errorf("ANSI wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	expected := `<pre class="monkey"><span class="keyword">if</span> <span class="punctuation">(</span>` +
+		`<span class="identifier">a</span> <span class="operator">&lt;</span> <span class="literal">1</span>` +
+		`<span class="punctuation">)</span> <span class="illegal">&amp;</span></pre>`
+
+	if got := HTML("if (a < 1) &"); got != expected {
+		t.Errorf("HTML wrong.\nexpected=%s\ngot=%s", expected, got)
+	}
+
+	page := Document("<example>", "1")
+	for _, part := range []string{"<!DOCTYPE html>", "<title>&lt;example&gt;</title>", STYLESHEET, `<span class="literal">1</span>`} {
+		if !strings.Contains(page, part) {
+			t.Errorf("document has no %q", part)
+		}
+	}
+}
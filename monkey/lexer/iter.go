@@ -0,0 +1,23 @@
+package lexer
+
+import (
+	"iter"
+	"monkey/token"
+)
+
+// Tokens returns the tokens of src up to but not including EOF.
+func Tokens(src string, opts ...Option) iter.Seq[token.Token] {
+	return New(src, opts...).Tokens()
+}
+
+// Tokens yields the remaining tokens of l up to but not including EOF.
+func (l *Lexer) Tokens() iter.Seq[token.Token] {
+	return func(yield func(token.Token) bool) {
+		for {
+			tok := l.NextToken()
+			if tok.Type == token.EOF || !yield(tok) {
+				return
+			}
+		}
+	}
+}
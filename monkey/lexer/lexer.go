@@ -1,108 +1,317 @@
 package lexer
 
-import "monkey/token"
+import (
+	"io"
+	"monkey/token"
+	"sort"
+	"unsafe"
+)
 
 type Lexer struct {
-	input         string
+	// input is the bytes of source, or the current window of the stream
+	// for a lexer reading from an io.Reader.
+	input         []byte
+	source        string
 	position      int
 	read_position int
 	ch            byte
+
+	start int
+	end   int
+
+	insert_semicolon bool
+	// open holds the LPAREN and LBRACE tokens not yet closed. No semicolons
+	// are inserted while the innermost one is a parenthesis.
+	open []token.TokenType
+
+	file *token.File
+
+	// Set for lexers reading from an io.Reader, see New_reader. input then
+	// holds a window of the stream starting at byte offset base.
+	reader      io.Reader
+	base        int
+	buffer_size int
+	eof         bool
+	err         error
+	// literals interns the literals of a reader-backed lexer, see literal.
+	literals map[string]string
+
+	operators []operator
+	keywords  map[string]token.TokenType
 }
 
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+type operator struct {
+	literal string
+	t       token.TokenType
+}
+
+type Option func(*Lexer)
+
+// With_operator makes the lexer produce a token of type t for literal,
+// which takes priority over any shorter built-in operator it starts with.
+func With_operator(literal string, t token.TokenType) Option {
+	return func(l *Lexer) {
+		l.operators = append(l.operators, operator{literal, t})
+		sort.SliceStable(l.operators, func(i, j int) bool {
+			return len(l.operators[i].literal) > len(l.operators[j].literal)
+		})
+	}
+}
+
+// With_keyword makes the lexer produce a token of type t for the
+// identifier word.
+func With_keyword(word string, t token.TokenType) Option {
+	return func(l *Lexer) {
+		if l.keywords == nil {
+			l.keywords = make(map[string]token.TokenType)
+		}
+		l.keywords[word] = t
+	}
+}
+
+// With_file makes the lexer record line starts in f and report token
+// positions relative to it, typically a file from a shared token.FileSet.
+func With_file(f *token.File) Option {
+	return func(l *Lexer) {
+		l.file = f
+	}
+}
+
+func New(input string, opts ...Option) *Lexer {
+	// The lexer never writes to input, so it can share the string's
+	// bytes instead of copying them.
+	l := &Lexer{input: unsafe.Slice(unsafe.StringData(input), len(input)), source: input}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.file == nil {
+		l.file = token.New_file_set().Add_file("", len(input))
+	}
 	l.read_char()
+	l.skip_shebang()
 	return l
 }
 
+// File returns the file token positions are relative to.
+func (l *Lexer) File() *token.File {
+	return l.file
+}
+
 func (l *Lexer) read_char() {
+	if l.read_position >= len(l.input) && l.reader != nil {
+		l.fill()
+	}
 	if l.read_position >= len(l.input) {
 		l.ch = 0
 	} else {
 		l.ch = l.input[l.read_position]
+		if l.ch == '\n' {
+			l.file.Add_line(l.base + l.read_position + 1)
+		}
 	}
 	l.position = l.read_position
 	l.read_position += 1
 }
 
+// NextToken returns the next token. Like Go, the lexer turns a newline
+// into a SEMICOLON token with literal "\n" when the line ends with a token
+// that can end a statement: an identifier, a literal, return, ')' or '}'.
+// Newlines inside parentheses are ignored, so calls, parameter lists and
+// conditions can span lines. As in Go, else must follow the '}' of its if
+// on the same line.
 func (l *Lexer) NextToken() token.Token {
+	tok := l.next_token()
+	l.track_nesting(tok.Type)
+	l.insert_semicolon = ends_statement(tok.Type) && !l.in_parens()
+
+	start, _ := l.Span()
+	tok.Pos = l.file.Pos(start)
+	return tok
+}
+
+func (l *Lexer) track_nesting(t token.TokenType) {
+	switch t {
+	case token.LPAREN, token.LBRACE:
+		l.open = append(l.open, t)
+	case token.RPAREN, token.RBRACE:
+		if len(l.open) > 0 {
+			l.open = l.open[:len(l.open)-1]
+		}
+	}
+}
+
+func (l *Lexer) in_parens() bool {
+	return len(l.open) > 0 && l.open[len(l.open)-1] == token.LPAREN
+}
+
+func ends_statement(t token.TokenType) bool {
+	switch t {
+	case token.IDENT, token.INT, token.TRUE, token.FALSE,
+		token.RETURN, token.RPAREN, token.RBRACE:
+		return true
+	}
+	return false
+}
+
+func (l *Lexer) next_token() token.Token {
 	var tok token.Token
 
-	l.skip_whitespace()
+	at_newline := l.skip_whitespace()
+	l.start = l.offset()
+
+	if at_newline {
+		l.end = l.start
+		return token.Token{Type: token.SEMICOLON, Literal: "\n"}
+	}
+
+	if tok, ok := l.read_operator(); ok {
+		l.end = l.offset()
+		return tok
+	}
 
 	switch l.ch {
 	case '=':
 		if l.peek_char() == '=' {
-			ch := l.ch
 			l.read_char()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = l.new_token2(token.EQ)
 		} else {
-			tok = new_token(token.ASSIGN, l.ch)
+			tok = l.new_token(token.ASSIGN)
 		}
 	case '+':
-		tok = new_token(token.PLUS, l.ch)
+		tok = l.new_token(token.PLUS)
 	case '-':
-		tok = new_token(token.MINUS, l.ch)
+		tok = l.new_token(token.MINUS)
 	case '!':
 		if l.peek_char() == '=' {
-			ch := l.ch
 			l.read_char()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
+			tok = l.new_token2(token.NOT_EQ)
 		} else {
-			tok = new_token(token.BANG, l.ch)
+			tok = l.new_token(token.BANG)
 		}
 	case '/':
-		tok = new_token(token.SLASH, l.ch)
+		tok = l.new_token(token.SLASH)
 	case '*':
-		tok = new_token(token.ASTERISK, l.ch)
+		tok = l.new_token(token.ASTERISK)
 	case '<':
-		tok = new_token(token.LT, l.ch)
+		tok = l.new_token(token.LT)
 	case '>':
-		tok = new_token(token.GT, l.ch)
+		tok = l.new_token(token.GT)
 	case ';':
-		tok = new_token(token.SEMICOLON, l.ch)
+		tok = l.new_token(token.SEMICOLON)
 	case '(':
-		tok = new_token(token.LPAREN, l.ch)
+		tok = l.new_token(token.LPAREN)
 	case ')':
-		tok = new_token(token.RPAREN, l.ch)
+		tok = l.new_token(token.RPAREN)
 	case ',':
-		tok = new_token(token.COMMA, l.ch)
+		tok = l.new_token(token.COMMA)
 	case '{':
-		tok = new_token(token.LBRACE, l.ch)
+		tok = l.new_token(token.LBRACE)
 	case '}':
-		tok = new_token(token.RBRACE, l.ch)
+		tok = l.new_token(token.RBRACE)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
 		if is_letter(l.ch) {
 			tok.Literal = l.read_identifier()
-			tok.Type = token.Lookup_identifier(tok.Literal)
+			tok.Type = l.lookup_identifier(tok.Literal)
+			l.end = l.offset()
 			return tok
 		} else if is_digit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.read_number()
+			l.end = l.offset()
 			return tok
 		} else {
-			tok = new_token(token.ILLEGAL, l.ch)
+			tok = l.new_token(token.ILLEGAL)
 		}
 	}
 	l.read_char()
+	l.end = l.offset()
+	if tok.Type == token.EOF {
+		l.end = l.start
+	}
 	return tok
 }
 
+// Span returns the byte offsets of the last token returned by NextToken.
+func (l *Lexer) Span() (start, end int) {
+	size := l.base + len(l.input)
+	return min(l.start, size), min(l.end, size)
+}
+
+// offset returns the absolute byte offset of the current character.
+func (l *Lexer) offset() int {
+	return l.base + l.position
+}
+
+func (l *Lexer) read_operator() (token.Token, bool) {
+	if len(l.operators) == 0 {
+		return token.Token{}, false
+	}
+	if l.reader != nil {
+		l.ensure(len(l.operators[0].literal))
+	}
+	if l.position >= len(l.input) {
+		return token.Token{}, false
+	}
+
+	rest := l.input[l.position:]
+	for _, op := range l.operators {
+		if len(rest) >= len(op.literal) && string(rest[:len(op.literal)]) == op.literal {
+			for i := 0; i < len(op.literal); i++ {
+				l.read_char()
+			}
+			return token.Token{Type: op.t, Literal: op.literal}, true
+		}
+	}
+	return token.Token{}, false
+}
+
+func (l *Lexer) lookup_identifier(ident string) token.TokenType {
+	if tok, ok := l.keywords[ident]; ok {
+		return tok
+	}
+	return token.Lookup_identifier(ident)
+}
+
 func (l *Lexer) read_identifier() string {
-	position := l.position
+	position := l.offset()
 	for is_letter(l.ch) {
 		l.read_char()
 	}
-	return l.input[position:l.position]
+	return l.literal(position-l.base, l.position)
 }
 
-func (l *Lexer) skip_whitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// skip_whitespace skips whitespace and comments. It stops at a newline and
+// returns true if a semicolon must be inserted there.
+func (l *Lexer) skip_whitespace() bool {
+	for {
+		switch {
+		case l.ch == '\n' && l.insert_semicolon:
+			return true
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.start = l.offset()
+			l.read_char()
+		case l.ch == '/' && l.peek_char() == '/':
+			l.skip_comment()
+		default:
+			return false
+		}
+	}
+}
+
+// skip_shebang skips a "#!" line at the start of the input, so that
+// scripts can be made executable.
+func (l *Lexer) skip_shebang() {
+	if l.ch == '#' && l.peek_char() == '!' {
+		l.skip_comment()
+	}
+}
+
+func (l *Lexer) skip_comment() {
+	for l.ch != '\n' && l.ch != 0 {
+		l.start = l.offset()
 		l.read_char()
 	}
 }
@@ -112,14 +321,17 @@ func is_letter(ch byte) bool {
 }
 
 func (l *Lexer) read_number() string {
-	position := l.position
+	position := l.offset()
 	for is_digit(l.ch) {
 		l.read_char()
 	}
-	return l.input[position:l.position]
+	return l.literal(position-l.base, l.position)
 }
 
 func (l *Lexer) peek_char() byte {
+	if l.read_position >= len(l.input) && l.reader != nil {
+		l.fill()
+	}
 	if l.read_position >= len(l.input) {
 		return 0
 	} else {
@@ -130,6 +342,38 @@ func (l *Lexer) peek_char() byte {
 func is_digit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
-func new_token(tokenType token.TokenType, ch byte) token.Token {
-	return token.Token{Type: tokenType, Literal: string(ch)}
+
+// new_token and new_token2 slice the literal out of the input instead of
+// converting the current byte, so lexing a string does not allocate.
+func (l *Lexer) new_token(tokenType token.TokenType) token.Token {
+	return token.Token{Type: tokenType, Literal: l.literal(l.position, l.position+1)}
+}
+
+func (l *Lexer) new_token2(tokenType token.TokenType) token.Token {
+	return token.Token{Type: tokenType, Literal: l.literal(l.position-1, l.position+1)}
+}
+
+// MAX_INTERNED bounds how many distinct literals a reader-backed lexer
+// interns, so that a stream of ever new identifiers cannot grow the table
+// without limit.
+const MAX_INTERNED = 4096
+
+// literal returns the input between start and end. A string-backed lexer
+// slices its source. A reader-backed one must copy, since a slice would
+// keep the whole window it points into alive for as long as the token
+// is, and interns the copies so that the keywords, operators and names a
+// program repeats are only allocated once.
+func (l *Lexer) literal(start, end int) string {
+	if l.reader == nil {
+		return l.source[start:end]
+	}
+	if literal, ok := l.literals[string(l.input[start:end])]; ok {
+		return literal
+	}
+	literal := string(l.input[start:end])
+	if l.literals == nil || len(l.literals) >= MAX_INTERNED {
+		l.literals = make(map[string]string)
+	}
+	l.literals[literal] = literal
+	return literal
 }
@@ -1,7 +1,9 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"monkey/token"
 )
@@ -126,6 +128,7 @@ func Test_new_token(t *testing.T) {
 		{token.FALSE, "false"},
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
+		{token.SEMICOLON, "\n"},
 
 		{token.INT, "10"},
 		{token.EQ, "=="},
@@ -151,3 +154,280 @@ func Test_new_token(t *testing.T) {
 		}
 	}
 }
+
+func Test_comments(t *testing.T) {
+	input := `// leading
+	let x = 5; // trailing
+	x / y // last`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.IDENT, "y"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] = token type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected %q, got %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func Test_shebang(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nlet x = 1\n#!x"
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.ILLEGAL, token.BANG, token.IDENT, token.EOF}
+
+	lexers := map[string]*Lexer{
+		"string": New(input),
+		"reader": New_reader(iotest.OneByteReader(strings.NewReader(input)), With_buffer_size(1)),
+	}
+	for name, l := range lexers {
+		for i, tt := range expected {
+			tok := l.NextToken()
+			if tok.Type != tt {
+				t.Fatalf("%s: tests[%d] - token type wrong. expected=%q, got=%q", name, i, tt, tok.Type)
+			}
+			if i == 0 {
+				if start, _ := l.Span(); start != 22 {
+					t.Fatalf("%s: let starts at %d, expected 22", name, start)
+				}
+			}
+		}
+	}
+}
+
+func Test_span(t *testing.T) {
+	input := "let ab == 10;  "
+
+	tests := []struct {
+		expectedStart int
+		expectedEnd   int
+	}{
+		{0, 3},
+		{4, 6},
+		{7, 9},
+		{10, 12},
+		{12, 13},
+		{15, 15},
+		{15, 15},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		l.NextToken()
+		start, end := l.Span()
+
+		if start != tt.expectedStart || end != tt.expectedEnd {
+			t.Fatalf("tests[%d] - span wrong, expected [%d,%d), got [%d,%d)", i, tt.expectedStart, tt.expectedEnd, start, end)
+		}
+	}
+}
+
+func Test_illegal_bytes(t *testing.T) {
+	l := New("ü")
+
+	for i, expected := range []string{"\xc3", "\xbc"} {
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL || tok.Literal != expected {
+			t.Fatalf("tests[%d] - expected ILLEGAL %q, got %s %q", i, expected, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func Test_next_token_does_not_allocate(t *testing.T) {
+	l := New(strings.Repeat("let add = fn(x, y) { x + y != 10; };\n", 1000))
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		l.NextToken()
+	})
+	if allocs != 0 {
+		t.Errorf("NextToken allocated %.1f times per token", allocs)
+	}
+}
+
+func Test_tokens_iterator(t *testing.T) {
+	var types []token.TokenType
+	for tok := range Tokens("let x = 1;") {
+		types = append(types, tok.Type)
+	}
+
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON}
+	if len(types) != len(expected) {
+		t.Fatalf("wrong token count. expected=%v, got=%v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("tokens[%d] wrong. expected=%s, got=%s", i, expected[i], types[i])
+		}
+	}
+
+	l := New("a b c d")
+	for tok := range l.Tokens() {
+		if tok.Literal == "b" {
+			break
+		}
+	}
+	if tok := l.NextToken(); tok.Literal != "c" {
+		t.Errorf("lexer not resumable after break. got=%q", tok.Literal)
+	}
+}
+
+func Test_semicolon_insertion(t *testing.T) {
+	input := "x\n5 // five\ntrue +\n(a)\n{}\nreturn\nlet\n\n"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.SEMICOLON, "\n"},
+		{token.INT, "5"},
+		{token.SEMICOLON, "\n"},
+		{token.TRUE, "true"},
+		{token.PLUS, "+"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, "\n"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, "\n"},
+		{token.RETURN, "return"},
+		{token.SEMICOLON, "\n"},
+		{token.LET, "let"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] = token type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected %q, got %q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func Test_no_semicolons_in_parens(t *testing.T) {
+	input := "f(\n\ta,\n\tfn() {\n\t\tb\n\t}\n)\n"
+
+	expected := []token.TokenType{
+		token.IDENT, token.LPAREN, token.IDENT, token.COMMA,
+		token.FUNCTION, token.LPAREN, token.RPAREN, token.LBRACE,
+		token.IDENT, token.SEMICOLON, token.RBRACE,
+		token.RPAREN, token.SEMICOLON, token.EOF,
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q", i, want, tok.Type)
+		}
+	}
+}
+
+func Test_positions(t *testing.T) {
+	input := "let x\n  = 10;\n\nx"
+
+	tests := []struct {
+		expectedLiteral  string
+		expectedPosition string
+	}{
+		{"let", "1:1"},
+		{"x", "1:5"},
+		{"\n", "1:6"},
+		{"=", "2:3"},
+		{"10", "2:5"},
+		{";", "2:7"},
+		{"x", "4:1"},
+		{"", "4:2"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		position := l.File().Position(tok.Pos).String()
+
+		if tok.Literal != tt.expectedLiteral || position != tt.expectedPosition {
+			t.Fatalf("tests[%d] - expected %q at %s, got %q at %s", i,
+				tt.expectedLiteral, tt.expectedPosition, tok.Literal, position)
+		}
+	}
+}
+
+// lex_bounded lexes until EOF, giving up after limit tokens.
+func lex_bounded(l *Lexer, limit int) ([]spanned_token, bool) {
+	var tokens []spanned_token
+	for len(tokens) <= limit {
+		tok := l.NextToken()
+		start, end := l.Span()
+		tokens = append(tokens, spanned_token{tok, start, end})
+		if tok.Type == token.EOF {
+			return tokens, true
+		}
+	}
+	return tokens, false
+}
+
+func Fuzz_next_token(f *testing.F) {
+	f.Add(reader_input)
+	f.Add("let x = 5\n// comment\nx")
+	f.Add("\x00\xff!=== ==;")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		// Every token but an inserted semicolon consumes at least one byte,
+		// and a semicolon is only inserted after another token.
+		limit := 2*len(input) + 1
+
+		tokens, ok := lex_bounded(New(input), limit)
+		if !ok {
+			t.Fatalf("no EOF after %d tokens", limit)
+		}
+
+		previous := 0
+		for i, tok := range tokens {
+			if tok.start < previous || tok.end < tok.start || tok.end > len(input) {
+				t.Fatalf("tokens[%d] %+v: bad span after offset %d", i, tok, previous)
+			}
+			previous = tok.end
+
+			inserted := tok.tok.Type == token.SEMICOLON && tok.tok.Literal == "\n"
+			if !inserted && tok.tok.Literal != input[tok.start:tok.end] {
+				t.Fatalf("tokens[%d] %+v: literal is not the source %q", i, tok, input[tok.start:tok.end])
+			}
+		}
+
+		streamed, ok := lex_bounded(New_reader(iotest.OneByteReader(strings.NewReader(input)), With_buffer_size(3)), limit)
+		if !ok || len(streamed) != len(tokens) {
+			t.Fatalf("reader lexed %d tokens, want %d", len(streamed), len(tokens))
+		}
+		for i := range tokens {
+			if streamed[i] != tokens[i] {
+				t.Fatalf("reader tokens[%d] wrong. expected=%+v, got=%+v", i, tokens[i], streamed[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package lexer
+
+import (
+	"io"
+	"monkey/token"
+)
+
+const default_buffer_size = 64 * 1024
+
+// With_buffer_size sets how many bytes a reader-backed lexer requests from
+// its reader at a time.
+func With_buffer_size(n int) Option {
+	return func(l *Lexer) {
+		l.buffer_size = max(n, 1)
+	}
+}
+
+// New_reader returns a lexer that reads its input from r as it goes. Only
+// the current token and one buffer of lookahead are held in memory, so
+// inputs larger than memory can be lexed.
+func New_reader(r io.Reader, opts ...Option) *Lexer {
+	l := &Lexer{reader: r, buffer_size: default_buffer_size}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.file == nil {
+		l.file = token.New_file_set().Add_file("", -1)
+	}
+	l.read_char()
+	l.skip_shebang()
+	return l
+}
+
+// Err returns the first error other than io.EOF returned by the reader.
+// The lexer reports EOF once the reader fails.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// fill drops the input before the current token and appends the next
+// chunk from the reader. The window slides within one buffer, which only
+// grows when a token is longer than the buffer size.
+func (l *Lexer) fill() {
+	if l.eof {
+		return
+	}
+
+	keep := min(max(l.start-l.base, 0), l.position, len(l.input))
+	rest := len(l.input) - keep
+
+	buf := l.input
+	if cap(buf) < rest+l.buffer_size {
+		buf = make([]byte, rest, rest+l.buffer_size)
+	}
+	copy(buf[:rest], l.input[keep:])
+	buf = buf[:rest]
+
+	for {
+		n, err := l.reader.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			l.eof = true
+			break
+		}
+		if n > 0 {
+			break
+		}
+	}
+
+	l.input = buf
+	l.base += keep
+	l.file.Extend(l.base + len(l.input))
+	l.position -= keep
+	l.read_position -= keep
+}
+
+// ensure fills until at least n bytes from the current character are
+// buffered or the reader is exhausted.
+func (l *Lexer) ensure(n int) {
+	for !l.eof && len(l.input)-l.position < n {
+		l.fill()
+	}
+}
@@ -0,0 +1,153 @@
+package lexer
+
+import (
+	"errors"
+	"io"
+	"monkey/token"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unsafe"
+)
+
+const reader_input = `let five = 5;
+let ten = 10; // a comment that spans a buffer boundary
+let add = fn(x, y) {
+	x + y;
+};
+let result = add(five, ten);
+!-/*5;
+5 < 10 > 5;
+if (5 < 10) { return true; } else { return false; }
+10 == 10;
+10 != 9;
+let a_rather_long_identifier_name = 1234567890123;
+`
+
+type spanned_token struct {
+	tok        token.Token
+	start, end int
+}
+
+func lex_all(l *Lexer) []spanned_token {
+	var tokens []spanned_token
+	for {
+		tok := l.NextToken()
+		start, end := l.Span()
+		tokens = append(tokens, spanned_token{tok, start, end})
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+func Test_reader_matches_string(t *testing.T) {
+	expected := lex_all(New(reader_input, With_operator("|>", token.Register("|>"))))
+
+	for _, size := range []int{1, 2, 3, 7, 64, 4096} {
+		readers := map[string]io.Reader{
+			"plain":    strings.NewReader(reader_input),
+			"one byte": iotest.OneByteReader(strings.NewReader(reader_input)),
+			"half":     iotest.HalfReader(strings.NewReader(reader_input)),
+		}
+		for name, r := range readers {
+			l := New_reader(r, With_buffer_size(size), With_operator("|>", token.Register("|>")))
+			got := lex_all(l)
+
+			if len(got) != len(expected) {
+				t.Fatalf("%s reader, buffer %d: got %d tokens, want %d", name, size, len(got), len(expected))
+			}
+			for i := range expected {
+				if got[i] != expected[i] {
+					t.Fatalf("%s reader, buffer %d: tokens[%d] wrong. expected=%+v, got=%+v",
+						name, size, i, expected[i], got[i])
+				}
+			}
+			if l.Err() != nil {
+				t.Errorf("unexpected error: %v", l.Err())
+			}
+		}
+	}
+}
+
+func Test_reader_buffer_is_bounded(t *testing.T) {
+	input := strings.Repeat("let x = 1;\n", 10000)
+	l := New_reader(strings.NewReader(input), With_buffer_size(32))
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if cap(l.input) > 64 {
+			t.Fatalf("buffer grew to %d bytes", cap(l.input))
+		}
+	}
+}
+
+func Test_reader_literals_are_copied(t *testing.T) {
+	l := New_reader(strings.NewReader("let answer = 42 == x;"), With_buffer_size(8))
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Literal == "" {
+			continue
+		}
+		buffer := uintptr(unsafe.Pointer(unsafe.SliceData(l.input)))
+		literal := uintptr(unsafe.Pointer(unsafe.StringData(tok.Literal)))
+		if buffer <= literal && literal < buffer+uintptr(cap(l.input)) {
+			t.Errorf("literal %q points into the read buffer", tok.Literal)
+		}
+	}
+}
+
+func Test_reader_allocations(t *testing.T) {
+	input := strings.Repeat(reader_input, 100)
+
+	// The buffer is reused across refills and repeated literals are
+	// interned, so allocations do not grow with the input.
+	allocs := testing.AllocsPerRun(10, func() {
+		lex_to_eof(New_reader(strings.NewReader(input), With_buffer_size(256)))
+	})
+	if allocs > 100 {
+		t.Errorf("lexing %d bytes took %.0f allocations", len(input), allocs)
+	}
+}
+
+func Test_reader_error(t *testing.T) {
+	failure := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("let x = 12"), iotest.ErrReader(failure))
+	l := New_reader(r, With_buffer_size(4))
+
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+
+	if len(types) != 4 || types[3] != token.INT {
+		t.Errorf("tokens before the error wrong. got=%v", types)
+	}
+	if l.Err() != failure {
+		t.Errorf("Err() wrong. got=%v", l.Err())
+	}
+}
+
+func lex_to_eof(l *Lexer) {
+	for l.NextToken().Type != token.EOF {
+	}
+}
+
+func BenchmarkLexer_string(b *testing.B) {
+	input := strings.Repeat(reader_input, 100)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		lex_to_eof(New(input))
+	}
+}
+
+func BenchmarkLexer_reader(b *testing.B) {
+	input := strings.Repeat(reader_input, 100)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		lex_to_eof(New_reader(strings.NewReader(input)))
+	}
+}
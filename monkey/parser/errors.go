@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+// ParseError is a syntax error. Position is resolved from Pos when the
+// parser knows which file the tokens came from, and is invalid otherwise.
+//
+// The remaining fields carry what a diagnostic renderer needs beyond Msg:
+// the span of the offending token, what was expected and found in plain
+// words, a label for the token, related locations such as the opening
+// delimiter of an unclosed call, and a suggested fix in plain words along
+// with the edits that apply it.
+type ParseError struct {
+	Pos      token.Pos
+	End      token.Pos
+	Position token.Position
+	Msg      string
+
+	Expected string
+	Found    string
+	Label    string
+	Related  []Label
+	Help     string
+	Fixes    []Fix
+}
+
+// Label annotates the source between Pos and End.
+type Label struct {
+	Pos token.Pos
+	End token.Pos
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	if e.Position.Is_valid() {
+		return e.Position.String() + ": " + e.Msg
+	}
+	return e.Msg
+}
+
+// Summary describes the error in plain words, e.g. "expected `)`, found
+// `let`", falling back to Msg.
+func (e *ParseError) Summary() string {
+	if e.Expected == "" {
+		return e.Msg
+	}
+	return "expected " + e.Expected + ", found " + e.Found
+}
+
+// error_at records msg at tok and returns the error so that the caller
+// can describe it further. Its string form is prefixed with the resolved
+// location, e.g. "main.mk:3:7: expected ...". An error identical to the
+// last one is dropped, as when both a grouping and the call around it
+// miss the same ')'.
+func (p *Parser) error_at(tok token.Token, msg string) *ParseError {
+	err := &ParseError{Pos: tok.Pos, End: token_end(tok), Msg: msg, Found: Describe(tok)}
+	if p.bailed {
+		return err
+	}
+	if n := len(p.parse_errors); n > 0 && tok.Pos.Is_valid() &&
+		p.parse_errors[n-1].Pos == tok.Pos && p.parse_errors[n-1].Msg == msg {
+		return err
+	}
+
+	if p.positions != nil {
+		err.Position = p.positions.Position(tok.Pos)
+	}
+	p.parse_errors = append(p.parse_errors, err)
+	p.errors = append(p.errors, err.Error())
+	if len(p.parse_errors) == 1 && tok.Type == token.EOF {
+		p.incomplete = true
+	}
+	return err
+}
+
+// Incomplete reports whether parsing failed only because the input ended
+// too early, e.g. inside an unclosed '{' or after a trailing operator, so
+// that more input could still make it a valid program. The REPL uses it to
+// ask for another line instead of reporting an error.
+func (p *Parser) Incomplete() bool {
+	return p.incomplete
+}
+
+// expected_error reports that found is not the token of type t the
+// grammar requires. A missing closing delimiter is related to the
+// innermost open one.
+func (p *Parser) expected_error(t token.TokenType, found token.Token) {
+	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, found.Type)
+	err := p.error_at(found, msg)
+	err.Expected = describe_type(t)
+
+	n := len(p.delimiters)
+	if n == 0 || !closes(p.delimiters[n-1].open.Type, t) {
+		return
+	}
+	open := p.delimiters[n-1]
+	err.Label = fmt.Sprintf("expected %s to close the %s", err.Expected, open.what)
+	err.Related = append(err.Related, Label{
+		Pos: open.open.Pos,
+		End: token_end(open.open),
+		Msg: open.what + " started here",
+	})
+	p.suggest_closing(err, t, found)
+}
+
+// delimiter is an opening parenthesis or brace whose closing counterpart
+// the parser is still looking for.
+type delimiter struct {
+	open   token.Token
+	what   string
+	errors int
+}
+
+// push_delimiter records that the current token opens a construct
+// described as what, e.g. "call".
+func (p *Parser) push_delimiter(what string) {
+	p.delimiters = append(p.delimiters, delimiter{open: p.cur_token, what: what, errors: len(p.parse_errors)})
+}
+
+func (p *Parser) pop_delimiter() {
+	p.delimiters = p.delimiters[:len(p.delimiters)-1]
+}
+
+func closes(open, close token.TokenType) bool {
+	return open == token.LPAREN && close == token.RPAREN ||
+		open == token.LBRACE && close == token.RBRACE
+}
+
+// token_end returns the position just past tok. Inserted semicolons and
+// EOF take up no space.
+func token_end(tok token.Token) token.Pos {
+	if !tok.Pos.Is_valid() || tok.Type == token.EOF || is_inserted_semicolon(tok) {
+		return tok.Pos
+	}
+	return tok.Pos + token.Pos(len(tok.Literal))
+}
+
+// Describe names tok in plain words for error messages, e.g. "`let`",
+// "identifier `x`" or "end of line".
+func Describe(tok token.Token) string {
+	switch {
+	case tok.Type == token.EOF:
+		return "end of input"
+	case is_inserted_semicolon(tok):
+		return "end of line"
+	case tok.Type == token.IDENT:
+		return "identifier `" + tok.Literal + "`"
+	case tok.Type == token.INT:
+		return "number `" + tok.Literal + "`"
+	case tok.Type == token.ILLEGAL:
+		return fmt.Sprintf("unexpected character %q", tok.Literal)
+	}
+	return "`" + tok.Literal + "`"
+}
+
+func describe_type(t token.TokenType) string {
+	switch t {
+	case token.EOF:
+		return "end of input"
+	case token.IDENT:
+		return "an identifier"
+	case token.INT:
+		return "a number"
+	case token.FUNCTION:
+		return "`fn`"
+	case token.LET, token.TRUE, token.FALSE, token.IF, token.ELSE, token.RETURN:
+		return "`" + strings.ToLower(t.String()) + "`"
+	}
+	return "`" + t.String() + "`"
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+type Option func(*Parser)
+
+type (
+	Prefix_fn func(p *Parser) ast.Expression
+	Infix_fn  func(p *Parser, left ast.Expression) ast.Expression
+)
+
+type Associativity int
+
+const (
+	LEFT Associativity = iota
+	RIGHT
+)
+
+// With_prefix registers fn to parse expressions that start with a token of
+// type t, replacing any built-in handler.
+func With_prefix(t token.TokenType, fn Prefix_fn) Option {
+	return func(p *Parser) {
+		p.register_prefix(t, func() ast.Expression { return fn(p) })
+	}
+}
+
+// With_infix registers fn to parse t as an infix operator binding with the
+// given precedence. When fn is called the current token is the operator.
+// Use Binary for a plain ast.Infix_expression.
+func With_infix(t token.TokenType, precedence int, assoc Associativity, fn Infix_fn) Option {
+	return func(p *Parser) {
+		p.precedences[t] = precedence
+		p.right_assoc[t] = assoc == RIGHT
+		p.register_infix(t, func(left ast.Expression) ast.Expression { return fn(p, left) })
+	}
+}
+
+// Binary parses the right operand of the current operator and returns an
+// ast.Infix_expression, honouring the operator's associativity.
+func Binary(p *Parser, left ast.Expression) ast.Expression {
+	return p.parse_infix_expression(left)
+}
+
+func (p *Parser) Cur_token() token.Token  { return p.cur_token }
+func (p *Parser) Peek_token() token.Token { return p.peek_token }
+
+func (p *Parser) Next_token() { p.next_token() }
+
+// Expect_peek advances past the next token if it has type t and records an
+// error otherwise.
+func (p *Parser) Expect_peek(t token.TokenType) bool { return p.expect_peek(t) }
+
+// Parse_expression parses an expression starting at the current token,
+// stopping before any operator that binds no tighter than precedence.
+func (p *Parser) Parse_expression(precedence int) ast.Expression {
+	return p.parse_expression(precedence)
+}
+
+// Precedence returns how tightly t binds as an infix operator in p.
+func (p *Parser) Precedence(t token.TokenType) int {
+	if precedence, ok := p.precedences[t]; ok {
+		return precedence
+	}
+	return LOWEST
+}
+
+// Errorf records an error located at the current token.
+func (p *Parser) Errorf(format string, args ...interface{}) {
+	p.error_at(p.cur_token, fmt.Sprintf(format, args...))
+}
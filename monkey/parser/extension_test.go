@@ -0,0 +1,109 @@
+package parser_test
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"testing"
+)
+
+var (
+	PIPE  = token.Register("|>")
+	IN    = token.Register("IN")
+	POWER = token.Register("**")
+	TILDE = token.Register("~")
+)
+
+func parse_extended(t *testing.T, input string) string {
+	t.Helper()
+
+	l := lexer.New(input,
+		lexer.With_operator("|>", PIPE),
+		lexer.With_operator("**", POWER),
+		lexer.With_operator("~", TILDE),
+		lexer.With_keyword("in", IN),
+	)
+	p := parser.New(l,
+		parser.With_infix(PIPE, parser.LOWEST+1, parser.LEFT, parse_pipe),
+		parser.With_infix(IN, parser.LESSGREATER, parser.LEFT, parser.Binary),
+		parser.With_infix(POWER, parser.PRODUCT+1, parser.RIGHT, parser.Binary),
+		parser.With_prefix(TILDE, parse_complement),
+	)
+
+	program := p.Parse_program()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program.String()
+}
+
+// parse_pipe rewrites `x |> f` to the call `f(x)`.
+func parse_pipe(p *parser.Parser, left ast.Expression) ast.Expression {
+	precedence := p.Precedence(p.Cur_token().Type)
+	p.Next_token()
+
+	function := p.Parse_expression(precedence)
+	return &ast.Call_expression{
+		Token:     token.Token{Type: token.LPAREN, Literal: "("},
+		Function:  function,
+		Arguments: []ast.Expression{left},
+	}
+}
+
+func parse_complement(p *parser.Parser) ast.Expression {
+	expr := &ast.Prefix_expression{Token: p.Cur_token(), Operator: p.Cur_token().Literal}
+	p.Next_token()
+	expr.Right = p.Parse_expression(parser.PREFIX)
+	if expr.Right == nil {
+		p.Errorf("expected operand after %s", expr.Operator)
+	}
+	return expr
+}
+
+func TestCustomOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f", "f(x)"},
+		{"x |> f |> g", "g(f(x))"},
+		{"1 + 2 |> double", "double((1 + 2))"},
+		{"a in b == true", "((a in b) == true)"},
+		{"a + b in c", "((a + b) in c)"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"2 * 3 ** 2", "(2 * (3 ** 2))"},
+		{"~a ** 2", "((~a) ** 2)"},
+		{"a * b * c", "((a * b) * c)"},
+	}
+
+	for _, tt := range tests {
+		if got := parse_extended(t, tt.input); got != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestExtensionsArePerParser(t *testing.T) {
+	parse_extended(t, "a ** b")
+
+	p := parser.New(lexer.New("a in b"))
+	program := p.Parse_program()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	if program.String() != "a;in;b" {
+		t.Errorf("default parser picked up extension. got=%q", program.String())
+	}
+}
+
+func TestExtensionErrors(t *testing.T) {
+	l := lexer.New("~;", lexer.With_operator("~", TILDE))
+	p := parser.New(l, parser.With_prefix(TILDE, parse_complement))
+	p.Parse_program()
+
+	errors := p.Errors()
+	if len(errors) == 0 || errors[len(errors)-1] != "1:2: expected operand after ~" {
+		t.Errorf("custom handler error not reported. got=%v", errors)
+	}
+}
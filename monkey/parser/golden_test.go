@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"flag"
+	"monkey/astdump"
+	"monkey/lexer"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files in testdata")
+
+// TestGolden parses every testdata/*.monkey file and compares the AST and
+// the errors with the .golden file next to it. Run with -update to
+// regenerate the golden files after a deliberate change.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.monkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata/*.monkey files")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".monkey")
+
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := golden_dump(filepath.Base(file), string(source))
+			golden := strings.TrimSuffix(file, ".monkey") + ".golden"
+
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(expected) {
+				t.Errorf("%s does not match %s.\n--- expected\n%s\n--- got\n%s", file, golden, expected, got)
+			}
+		})
+	}
+}
+
+// golden_dump renders the parse of source as an S-expression followed by
+// the errors, if any.
+func golden_dump(filename, source string) string {
+	fset := token.New_file_set()
+	l := lexer.New(source, lexer.With_file(fset.Add_file(filename, len(source))))
+	p := New(l)
+	program := p.Parse_program()
+
+	var out strings.Builder
+	out.WriteString(astdump.Sexpr(program))
+
+	if len(p.Errors()) > 0 {
+		out.WriteString("\n-- errors --\n")
+		for _, msg := range p.Errors() {
+			out.WriteString(msg + "\n")
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"errors"
+	"iter"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// Statements parses src one statement at a time.
+func Statements(src string, opts ...Option) iter.Seq2[ast.Statement, error] {
+	return New(lexer.New(src), opts...).Statements()
+}
+
+// Statements parses the remaining input one top-level statement at a time,
+// so a caller can stop early without building the whole ast.Program. When a
+// statement has syntax errors they are yielded alongside it, and the
+// statement itself may be nil. The errors are also recorded
+// in Errors as usual.
+func (p *Parser) Statements() iter.Seq2[ast.Statement, error] {
+	return func(yield func(ast.Statement, error) bool) {
+		for p.cur_token.Type != token.EOF {
+			before := len(p.parse_errors)
+			statement := p.parse_statement()
+
+			var errs []error
+			for _, err := range p.parse_errors[before:] {
+				errs = append(errs, err)
+			}
+			p.next_token()
+
+			if statement == nil && len(errs) == 0 {
+				continue
+			}
+			if !yield(statement, errors.Join(errs...)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestStatementsIterator(t *testing.T) {
+	input := "let x = 5; x + 1; return x;"
+
+	var got []string
+	for stmt, err := range Statements(input) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, stmt.String())
+	}
+
+	expected := []string{"let x = 5;", "(x + 1)", "return x;"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("statements wrong. expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestStatementsIteratorErrors(t *testing.T) {
+	var errs []error
+	var last string
+	for stmt, err := range Statements("let = 1; let y = 2;") {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		last = stmt.String()
+	}
+
+	if len(errs) == 0 {
+		t.Fatalf("expected an error for the malformed let")
+	}
+	if !strings.Contains(errs[0].Error(), "expected next token to be IDENT") {
+		t.Errorf("wrong error. got=%q", errs[0])
+	}
+	if last != "let y = 2;" {
+		t.Errorf("iteration did not continue after the error. last=%q", last)
+	}
+}
+
+func TestStatementsIteratorStopsEarly(t *testing.T) {
+	l := lexer.New(strings.Repeat("let a = 1;\n", 1000))
+	p := New(l)
+
+	n := 0
+	for range p.Statements() {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+
+	if n != 3 {
+		t.Fatalf("expected 3 statements, got %d", n)
+	}
+	if p.cur_token.Type != token.LET {
+		t.Errorf("parser not positioned at the next statement. got=%s", p.cur_token.Type)
+	}
+}
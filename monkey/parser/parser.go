@@ -2,8 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"monkey/ast"
-	"monkey/lexer"
 	"monkey/token"
 	"strconv"
 )
@@ -24,6 +24,9 @@ const (
 	CALL        // myFunction(X)
 )
 
+// MAX_DEPTH is the default limit on how deeply expressions may nest.
+const MAX_DEPTH = 1000
+
 var precedences = map[token.TokenType]int{
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
@@ -37,25 +40,61 @@ var precedences = map[token.TokenType]int{
 }
 
 type Parser struct {
-	l *lexer.Lexer
+	l token.TokenSource
 
 	cur_token  token.Token
 	peek_token token.Token
 
-	errors []string
+	errors       []string
+	parse_errors []*ParseError
+	delimiters   []delimiter
+	incomplete   bool
 
 	prefix_Parse_Fns map[token.TokenType]prefix_Parse_Fn
 	infix_Parse_Fns  map[token.TokenType]infix_Parse_fn
+	precedences      map[token.TokenType]int
+	right_assoc      map[token.TokenType]bool
+
+	index int
+	spans map[ast.Node]Span
+
+	trace_out   io.Writer
+	trace_level int
+
+	strict bool
+
+	// misspelling is carried over from a statement that did not end in a
+	// semicolon to the next, which may be the rest of it, as the block of
+	// "fi (x) { y }" is.
+	misspelling *misspelling
+
+	depth     int
+	max_depth int
+	bailed    bool
+
+	positions interface {
+		Position(token.Pos) token.Position
+	}
 }
 
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+// Span is the range of tokens a node was parsed from, as indexes into the
+// token stream counted from zero. Both ends are inclusive.
+type Span struct {
+	First int
+	Last  int
+}
 
-	p.next_token()
-	p.next_token()
+func New(l token.TokenSource, opts ...Option) *Parser {
+	p := &Parser{l: l, errors: []string{}, max_depth: MAX_DEPTH}
 
 	p.prefix_Parse_Fns = make(map[token.TokenType]prefix_Parse_Fn)
 	p.infix_Parse_Fns = make(map[token.TokenType]infix_Parse_fn)
+	p.precedences = make(map[token.TokenType]int)
+	p.right_assoc = make(map[token.TokenType]bool)
+
+	for t, precedence := range precedences {
+		p.precedences[t] = precedence
+	}
 
 	p.register_prefix(token.IDENT, p.parse_identifier)
 	p.register_prefix(token.INT, p.parse_integer_literal)
@@ -77,12 +116,81 @@ func New(l *lexer.Lexer) *Parser {
 	p.register_infix(token.GT, p.parse_infix_expression)
 	p.register_infix(token.LPAREN, p.parse_call_expression)
 
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.positions == nil {
+		if f, ok := l.(interface{ File() *token.File }); ok {
+			p.positions = f.File()
+		}
+	}
+
+	if p.strict {
+		p.l = token.Filter(p.l, func(tok token.Token) bool {
+			return !is_inserted_semicolon(tok)
+		})
+	}
+
+	p.next_token()
+	p.next_token()
+
 	return p
 }
 
+// With_file_set resolves the positions in error messages through fset,
+// for token sources that mix tokens from several files.
+func With_file_set(fset *token.FileSet) Option {
+	return func(p *Parser) {
+		p.positions = fset
+	}
+}
+
+// With_max_depth limits how deeply expressions, blocks and function
+// literals may nest, so that hostile input cannot exhaust the stack. The
+// default is MAX_DEPTH; n <= 0 removes the limit.
+func With_max_depth(n int) Option {
+	return func(p *Parser) {
+		p.max_depth = n
+	}
+}
+
+// With_strict_semicolons makes newlines insignificant: semicolons inserted
+// by the lexer are dropped and every statement must end with an explicit
+// semicolon, unless it ends with '}' or is the last one in a block or file.
+func With_strict_semicolons() Option {
+	return func(p *Parser) {
+		p.strict = true
+	}
+}
+
+func is_inserted_semicolon(tok token.Token) bool {
+	return tok.Type == token.SEMICOLON && tok.Literal == "\n"
+}
+
+// end_statement consumes the semicolon that terminates a statement.
+func (p *Parser) end_statement() {
+	if p.peek_token_is(token.SEMICOLON) {
+		p.next_token()
+		return
+	}
+	if p.strict && !p.cur_token_is(token.RBRACE) &&
+		!p.peek_token_is(token.RBRACE) && !p.peek_token_is(token.EOF) {
+		msg := fmt.Sprintf("expected ; after statement, got %s instead", p.peek_token.Type)
+		err := p.error_at(p.peek_token, msg)
+		err.Expected = "`;`"
+		err.Label = "expected `;` to end the statement"
+	}
+}
+
 func (p *Parser) parse_call_expression(function ast.Expression) ast.Expression {
 	expr := &ast.Call_expression{Token: p.cur_token, Function: function}
+	p.push_delimiter("call")
+	defer p.pop_delimiter()
 	expr.Arguments = p.parse_call_arguments()
+	if expr.Arguments == nil {
+		return nil
+	}
 	return expr
 }
 
@@ -105,6 +213,11 @@ func (p *Parser) parse_call_arguments() []ast.Expression {
 	if !p.expect_peek(token.RPAREN) {
 		return nil
 	}
+	for _, argument := range arguments {
+		if argument == nil {
+			return nil
+		}
+	}
 	return arguments
 }
 
@@ -114,7 +227,12 @@ func (p *Parser) parse_function_literal() ast.Expression {
 		return nil
 	}
 
+	p.push_delimiter("parameter list")
 	expr.Parameters = p.parse_function_parameters()
+	p.pop_delimiter()
+	if expr.Parameters == nil {
+		return nil
+	}
 
 	if !p.expect_peek(token.LBRACE) {
 		return nil
@@ -132,15 +250,21 @@ func (p *Parser) parse_function_parameters() []*ast.Identifier {
 		p.next_token()
 		return identifiers
 	}
-	p.next_token()
+	if !p.expect_peek(token.IDENT) {
+		return nil
+	}
 	ident := &ast.Identifier{Token: p.cur_token, Value: p.cur_token.Literal}
 	identifiers = append(identifiers, ident)
+	p.record_span(ident, p.cur_index())
 
 	for p.peek_token_is(token.COMMA) {
 		p.next_token()
-		p.next_token()
+		if !p.expect_peek(token.IDENT) {
+			return nil
+		}
 		ident := &ast.Identifier{Token: p.cur_token, Value: p.cur_token.Literal}
 		identifiers = append(identifiers, ident)
+		p.record_span(ident, p.cur_index())
 	}
 	if !p.expect_peek(token.RPAREN) {
 		return nil
@@ -156,14 +280,16 @@ func (p *Parser) parse_if_expression() ast.Expression {
 	if !p.expect_peek(token.LPAREN) {
 		return nil
 	}
+	p.push_delimiter("condition")
 
 	p.next_token()
 
 	expr.Condition = p.parse_expression(LOWEST)
-
-	if !p.expect_peek(token.RPAREN) {
+	if expr.Condition == nil || !p.expect_peek(token.RPAREN) {
+		p.pop_delimiter()
 		return nil
 	}
+	p.pop_delimiter()
 
 	if !p.expect_peek(token.LBRACE) {
 		return nil
@@ -187,6 +313,9 @@ func (p *Parser) parse_if_expression() ast.Expression {
 func (p *Parser) parse_block_statement() *ast.Block_statement {
 	expr := &ast.Block_statement{Token: p.cur_token}
 	expr.Statements = []ast.Statement{}
+	first := p.cur_index()
+	p.push_delimiter("block")
+	defer p.pop_delimiter()
 
 	p.next_token()
 
@@ -198,11 +327,17 @@ func (p *Parser) parse_block_statement() *ast.Block_statement {
 		p.next_token()
 
 	}
+	if p.cur_token_is(token.EOF) {
+		p.expected_error(token.RBRACE, p.cur_token)
+	}
+	p.record_span(expr, first)
 	return expr
 
 }
 
 func (p *Parser) parse_grouped_expression() ast.Expression {
+	p.push_delimiter("parenthesized expression")
+	defer p.pop_delimiter()
 	p.next_token()
 
 	expr := p.parse_expression(LOWEST)
@@ -223,20 +358,26 @@ func (p *Parser) parse_infix_expression(left ast.Expression) ast.Expression {
 		Operator: p.cur_token.Literal,
 		Left:     left}
 	precedence := p.cur_precendence()
+	if p.right_assoc[p.cur_token.Type] {
+		precedence--
+	}
 	p.next_token()
 	expr.Right = p.parse_expression(precedence)
+	if expr.Right == nil {
+		return nil
+	}
 	return expr
 }
 
 func (p *Parser) peek_precedence() int {
-	if p, ok := precedences[p.peek_token.Type]; ok {
+	if p, ok := p.precedences[p.peek_token.Type]; ok {
 		return p
 	}
 	return LOWEST
 }
 
 func (p *Parser) cur_precendence() int {
-	if p, ok := precedences[p.cur_token.Type]; ok {
+	if p, ok := p.precedences[p.cur_token.Type]; ok {
 		return p
 	}
 	return LOWEST
@@ -248,6 +389,9 @@ func (p *Parser) parse_prefix_expression() ast.Expression {
 	p.next_token()
 
 	exp.Right = p.parse_expression(PREFIX)
+	if exp.Right == nil {
+		return nil
+	}
 
 	return exp
 }
@@ -258,7 +402,8 @@ func (p *Parser) parse_integer_literal() ast.Expression {
 	value, err := strconv.ParseInt(p.cur_token.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.cur_token.Literal)
-		p.errors = append(p.errors, msg)
+		err := p.error_at(p.cur_token, msg)
+		err.Label = "does not fit in a 64-bit integer"
 		return nil
 	}
 	lit.Value = value
@@ -275,19 +420,59 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Parse_errors returns the same errors as Errors, with their positions.
+func (p *Parser) Parse_errors() []*ParseError {
+	return p.parse_errors
+}
+
+// bail_out reports that the input nests too deeply and skips the rest of
+// it. Errors the enclosing parse functions would report while unwinding
+// are dropped, as they are all caused by the skipped input.
+func (p *Parser) bail_out() {
+	msg := fmt.Sprintf("expression nested too deeply, the limit is %d levels", p.max_depth)
+	p.error_at(p.cur_token, msg)
+	p.bailed = true
+
+	for !p.cur_token_is(token.EOF) {
+		p.next_token()
+	}
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peek_token.Type)
-	p.errors = append(p.errors, msg)
+	p.expected_error(t, p.peek_token)
 }
 
 func (p *Parser) next_token() {
 	p.cur_token = p.peek_token
 	p.peek_token = p.l.NextToken()
+	p.index++
+}
+
+// Record_spans makes the parser remember the token span of every node it
+// produces. It must be called before Parse_program.
+func (p *Parser) Record_spans() {
+	p.spans = make(map[ast.Node]Span)
+}
+
+func (p *Parser) Spans() map[ast.Node]Span {
+	return p.spans
+}
+
+func (p *Parser) cur_index() int {
+	return p.index - 2
+}
+
+func (p *Parser) record_span(node ast.Node, first int) {
+	if p.spans == nil || ast.Is_nil(node) {
+		return
+	}
+	p.spans[node] = Span{First: first, Last: p.cur_index()}
 }
 
 func (p *Parser) Parse_program() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
+	first := p.cur_index()
 
 	for p.cur_token.Type != token.EOF {
 		statement := p.parse_statement()
@@ -297,19 +482,40 @@ func (p *Parser) Parse_program() *ast.Program {
 		}
 		p.next_token()
 	}
+	if p.spans != nil {
+		p.spans[program] = Span{First: first, Last: p.cur_index() - 1}
+	}
 	return program
 }
 
 func (p *Parser) parse_statement() ast.Statement {
-	switch p.cur_token.Type {
+	var statement ast.Statement
+	first := p.cur_index()
+
+	note := p.misspelling
+	p.misspelling = nil
+	ident := p.cur_token
+	keyword, suggestion := p.check_misspelled_keyword()
+	if suggestion != "" {
+		note = &misspelling{ident, suggestion}
+	}
+	before := len(p.parse_errors)
+
+	switch keyword.Type {
 	case token.LET:
-		return p.parse_let_statement()
+		statement = p.parse_let_statement(keyword)
 	case token.RETURN:
-		return p.parse_return_statement()
+		statement = p.parse_return_statement(keyword)
 	default:
-		return p.parse_expression_statement()
+		statement = p.parse_expression_statement()
 	}
 
+	if note != nil {
+		p.note_misspelling(note, before)
+	}
+
+	p.record_span(statement, first)
+	return statement
 }
 
 func (p *Parser) parse_expression_statement() ast.Statement {
@@ -317,13 +523,26 @@ func (p *Parser) parse_expression_statement() ast.Statement {
 
 	statement.Expression = p.parse_expression(LOWEST)
 
-	if p.peek_token_is(token.SEMICOLON) {
-		p.next_token()
+	p.end_statement()
+	if statement.Expression == nil {
+		return nil
 	}
 	return statement
 }
 
 func (p *Parser) parse_expression(precedence int) ast.Expression {
+	if p.trace_out != nil {
+		defer p.untrace(p.trace(fmt.Sprintf("parse_expression(%s) at %s",
+			precedence_name(precedence), describe_token(p.cur_token))))
+	}
+
+	if p.max_depth > 0 && p.depth >= p.max_depth {
+		p.bail_out()
+		return nil
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
 	prefix := p.prefix_Parse_Fns[p.cur_token.Type]
 
 	if prefix == nil {
@@ -331,49 +550,63 @@ func (p *Parser) parse_expression(precedence int) ast.Expression {
 		return nil
 	}
 
-	left_expr := prefix()
+	first := p.cur_index()
+	left_expr := p.call_prefix(prefix)
+	if ast.Is_nil(left_expr) {
+		return nil
+	}
+	p.record_span(left_expr, first)
 
 	for !p.peek_token_is(token.SEMICOLON) && precedence < p.peek_precedence() {
 		infix := p.infix_Parse_Fns[p.peek_token.Type]
 		if infix == nil {
 			return left_expr
 		}
+		p.trace_decision("continue with", precedence)
 		p.next_token()
 
-		left_expr = infix(left_expr)
+		left_expr = p.call_infix(infix, left_expr)
+		if ast.Is_nil(left_expr) {
+			return nil
+		}
+		p.record_span(left_expr, first)
 	}
+	p.trace_decision("stop before", precedence)
 
 	return left_expr
 }
 
-func (p *Parser) parse_return_statement() ast.Statement {
-	statement := &ast.Return_statement{Token: p.cur_token}
+func (p *Parser) parse_return_statement(keyword token.Token) ast.Statement {
+	statement := &ast.Return_statement{Token: keyword}
 	p.next_token()
 
 	statement.Return_value = p.parse_expression(LOWEST)
 
-	if p.peek_token_is(token.SEMICOLON) {
-		p.next_token()
+	p.end_statement()
+	if statement.Return_value == nil {
+		return nil
 	}
 	return statement
 }
 
-func (p *Parser) parse_let_statement() ast.Statement {
-	statement := &ast.Let_statement{Token: p.cur_token}
+func (p *Parser) parse_let_statement(keyword token.Token) ast.Statement {
+	statement := &ast.Let_statement{Token: keyword}
 
 	if !p.expect_peek(token.IDENT) {
 		return nil
 	}
 
 	statement.Name = &ast.Identifier{Token: p.cur_token, Value: p.cur_token.Literal}
+	p.record_span(statement.Name, p.cur_index())
 	if !p.expect_peek(token.ASSIGN) {
 		return nil
 	}
 	p.next_token()
 
 	statement.Value = p.parse_expression(LOWEST)
-	if p.peek_token_is(token.SEMICOLON) {
-		p.next_token()
+	p.end_statement()
+	if statement.Value == nil {
+		return nil
 	}
 	return statement
 }
@@ -399,7 +632,15 @@ func (p *Parser) expect_peek(t token.TokenType) bool {
 
 func (p *Parser) no_prefix_parse_fn_error(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	err := p.error_at(p.cur_token, msg)
+	err.Expected = "an expression"
+	switch t {
+	case token.RPAREN, token.RBRACE:
+		p.suggest_removing(err)
+	case token.ELSE:
+		// The newline after the } of the if ended the statement.
+		err.Help = "move else to the line of the } that closes its if"
+	}
 }
 
 func (p *Parser) register_prefix(tokenType token.TokenType, fn prefix_Parse_Fn) {
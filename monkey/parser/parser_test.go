@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -275,7 +277,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		},
 		{
 			"3 + 4; -5 * 5",
-			"(3 + 4)((-5) * 5)",
+			"(3 + 4);((-5) * 5)",
 		},
 		{
 			"5 > 4 == 3 < 4",
@@ -815,3 +817,391 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestTokenSources(t *testing.T) {
+	tokens := []token.Token{
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.INT, Literal: "5"},
+		{Type: token.SEMICOLON, Literal: ";"},
+	}
+
+	p := New(token.From_slice(tokens))
+	program := p.Parse_program()
+	checkParserErrors(t, p)
+
+	if program.String() != "let x = 5;" {
+		t.Errorf("slice source parsed wrong. got=%q", program.String())
+	}
+
+	filtered := token.Filter(lexer.New("let @ y = $ 1 + 2;"), func(tok token.Token) bool {
+		return tok.Type != token.ILLEGAL
+	})
+	p = New(filtered)
+	program = p.Parse_program()
+	checkParserErrors(t, p)
+
+	if program.String() != "let y = (1 + 2);" {
+		t.Errorf("filtered source parsed wrong. got=%q", program.String())
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	fset := token.New_file_set()
+
+	first := "let x = 1;\nlet y = 2;"
+	second := "let a = 1;\n\n  let = 5;\n"
+
+	l := lexer.New(first, lexer.With_file(fset.Add_file("first.mk", len(first))))
+	New(l).Parse_program()
+
+	l = lexer.New(second, lexer.With_file(fset.Add_file("second.mk", len(second))))
+	p := New(l)
+	p.Parse_program()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected errors")
+	}
+	expected := "second.mk:3:7: expected next token to be IDENT, got = instead"
+	if p.Errors()[0] != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, p.Errors()[0])
+	}
+
+	p = New(token.From_slice([]token.Token{{Type: token.LET, Literal: "let"}}))
+	p.Parse_program()
+	if p.Errors()[0] != "expected next token to be IDENT, got EOF instead" {
+		t.Errorf("error without position wrong. got=%q", p.Errors()[0])
+	}
+}
+
+const benchmark_input = `let five = 5;
+let ten = 10;
+let add = fn(x, y) { x + y; };
+let result = add(five, ten);
+if (5 < 10 == !false) { return -result * 2; } else { return add(1, 2 * 3, 4 + 5); }
+`
+
+func BenchmarkParse_program(b *testing.B) {
+	input := strings.Repeat(benchmark_input, 100)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(input))
+		p.Parse_program()
+		if len(p.Errors()) != 0 {
+			b.Fatalf("parser errors: %v", p.Errors())
+		}
+	}
+}
+
+func TestAutomaticSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5\n(y)", "let x = 5;y"},
+		{"a\n-b", "a;(-b)"},
+		{"a\n(b)\n", "a;b"},
+		{"let f = fn(x) {\n\tx + 1\n}\nf(2)", "let f = fn(x) { (x + 1) };f(2)"},
+		{"1 +\n2", "(1 + 2)"},
+		{"add(1,\n2)", "add(1, 2)"},
+		{"if (a) {\n\tb\n} else {\n\tc\n}\n", "if (a) { b } else { c }"},
+		{"return x // done\nx", "return x;x"},
+		{"let x = 5;\nx", "let x = 5;x"},
+		{"add(\n\t1,\n\t2\n)", "add(1, 2)"},
+		{"let f = fn(\n\ta,\n\tb\n) {\n\ta\n\tb\n}", "let f = fn(a, b) { a;b };"},
+		{"if (\n\tx\n) {\n\ty\n}", "if (x) { y }"},
+		{"f(fn(x) {\n\tlet y = x\n\ty\n}, 2)\ng", "f(fn(x) { let y = x;y }, 2);g"},
+		{"(a\n)\n(b)", "a;b"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.Parse_program()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestElseOnNextLine(t *testing.T) {
+	p := New(lexer.New("if (a) {\n\tb\n}\nelse {\n\tc\n}"))
+	p.Parse_program()
+
+	errors := p.Parse_errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected an error for else on its own line")
+	}
+	if errors[0].Msg != "no prefix parse function for ELSE found" ||
+		errors[0].Help != "move else to the line of the } that closes its if" {
+		t.Errorf("wrong first error. got=%+v", errors[0])
+	}
+}
+
+func TestStrictSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		errors   []string
+	}{
+		{"let x = 5\n(y)", "let x = 5(y);", nil},
+		{"a\n-b", "(a - b)", nil},
+		{"let x = 5;\nlet y = x", "let x = 5;let y = x;", nil},
+		{"if (a) { b }\nlet c = 1;", "if (a) { b };let c = 1;", nil},
+		{"fn() { let a = 1\nlet b = 2 }", "fn() { let a = 1;let b = 2; }", []string{
+			"2:1: expected ; after statement, got LET instead",
+		}},
+		{"let x = 5\nlet y = 6", "let x = 5;let y = 6;", []string{
+			"2:1: expected ; after statement, got LET instead",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input), With_strict_semicolons())
+		program := p.Parse_program()
+
+		if program.String() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+		if strings.Join(p.Errors(), "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("input %q: errors wrong. expected=%q, got=%q", tt.input, tt.errors, p.Errors())
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		depth    int
+		expected string
+	}{
+		{strings.Repeat("(", 100000), MAX_DEPTH, "1:1001: expression nested too deeply, the limit is 1000 levels"},
+		{strings.Repeat("-", 100000) + "1", MAX_DEPTH, "1:1001: expression nested too deeply, the limit is 1000 levels"},
+		{"let x = " + strings.Repeat("!", 50000), MAX_DEPTH, "1:1009: expression nested too deeply, the limit is 1000 levels"},
+		{strings.Repeat("fn() { ", 10) + strings.Repeat("}", 10), 5, "1:36: expression nested too deeply, the limit is 5 levels"},
+		{strings.Repeat("f(", 10) + strings.Repeat(")", 10), 3, "1:7: expression nested too deeply, the limit is 3 levels"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input), With_max_depth(tt.depth))
+		program := p.Parse_program()
+		_ = program.String()
+
+		if len(p.Errors()) != 1 || p.Errors()[0] != tt.expected {
+			t.Errorf("input %.20q...: expected one error %q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+
+	input := strings.Repeat("(", 2000) + "1" + strings.Repeat(")", 2000)
+	p := New(lexer.New(input), With_max_depth(0))
+	program := p.Parse_program()
+	checkParserErrors(t, p)
+	if program.String() != "1" {
+		t.Errorf("unlimited depth parsed wrong. got=%q", program.String())
+	}
+}
+
+// missing_child reports whether node or any node below it lacks a child
+// the grammar requires.
+func missing_child(node ast.Node) bool {
+	if ast.Is_nil(node) {
+		return true
+	}
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if missing_child(s) {
+				return true
+			}
+		}
+	case *ast.Block_statement:
+		for _, s := range node.Statements {
+			if missing_child(s) {
+				return true
+			}
+		}
+	case *ast.Let_statement:
+		return missing_child(node.Name) || missing_child(node.Value)
+	case *ast.Return_statement:
+		return missing_child(node.Return_value)
+	case *ast.Expression_statement:
+		return missing_child(node.Expression)
+	case *ast.Prefix_expression:
+		return missing_child(node.Right)
+	case *ast.Infix_expression:
+		return missing_child(node.Left) || missing_child(node.Right)
+	case *ast.If_expression:
+		return missing_child(node.Condition) || missing_child(node.Consequence) ||
+			node.Alternative != nil && missing_child(node.Alternative)
+	case *ast.Function_literal:
+		for _, parameter := range node.Parameters {
+			if missing_child(parameter) {
+				return true
+			}
+		}
+		return missing_child(node.Body)
+	case *ast.Call_expression:
+		for _, argument := range node.Arguments {
+			if missing_child(argument) {
+				return true
+			}
+		}
+		return missing_child(node.Function)
+	}
+	return false
+}
+
+func TestMalformedInput(t *testing.T) {
+	inputs := []string{
+		"1 +",
+		"-",
+		"!(",
+		"a * (b +",
+		"f(1, , 2)",
+		"f(1, 2",
+		"f(99999999999999999999)",
+		"if (",
+		"if () { }",
+		"if (x) { y } else",
+		"fn(",
+		"fn(1) { }",
+		"fn(a, ) { a }",
+		"fn(a { a }",
+		"let = 5",
+		"let x",
+		"let x = ;",
+		"return }",
+		"1 + 99999999999999999999 * 2",
+		"((((1 + 2)",
+		"} ) ,",
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.Parse_program()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("input %q: expected errors, got program %q", input, program.String())
+		}
+		if missing_child(program) {
+			t.Errorf("input %q: node with a missing child in %q", input, program.String())
+		}
+
+		for i, err := range p.Parse_errors() {
+			if err.Error() != p.Errors()[i] || !err.Position.Is_valid() {
+				t.Errorf("input %q: Parse_errors()[%d] = %q at %v, Errors()[%d] = %q",
+					input, i, err.Error(), err.Position, i, p.Errors()[i])
+			}
+		}
+	}
+}
+
+func Fuzz_parse_program(f *testing.F) {
+	f.Add(benchmark_input)
+	f.Add("let f = fn(x) {\n\tx + 1\n}\nf(2)")
+	f.Add("if (a) { b } else { c }; -(1 + !2) * f(3, 4)")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.Parse_program()
+		source := program.String()
+
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		// String parenthesizes every operator, so the printed source can
+		// nest much deeper than the input did.
+		p = New(lexer.New(source), With_max_depth(0))
+		reparsed := p.Parse_program()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("String() of %q is %q, which does not parse: %v", input, source, p.Errors())
+		}
+		if !ast.Equal(program, reparsed, ast.Ignore_tokens()) {
+			t.Fatalf("String() of %q is %q, which parses as %q", input, source, reparsed.String())
+		}
+	})
+}
+
+func TestParseErrorDetails(t *testing.T) {
+	input := "let x = f(1, (2\nlet y = 3"
+	p := New(lexer.New(input))
+	p.Parse_program()
+
+	errs := p.Parse_errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got=%q", p.Errors())
+	}
+	err := errs[0]
+
+	if err.Summary() != "expected `)`, found `let`" {
+		t.Errorf("Summary wrong. got=%q", err.Summary())
+	}
+	if err.Label != "expected `)` to close the parenthesized expression" {
+		t.Errorf("Label wrong. got=%q", err.Label)
+	}
+	if err.Pos != 17 || err.End != 20 {
+		t.Errorf("span wrong. got=%d..%d", err.Pos, err.End)
+	}
+	if len(err.Related) != 1 || err.Related[0].Msg != "parenthesized expression started here" {
+		t.Fatalf("Related wrong. got=%+v", err.Related)
+	}
+	if open := err.Related[0]; open.End-open.Pos != 1 || input[open.Pos-1] != '(' || open.Pos != 14 {
+		t.Errorf("Related span wrong. got=%d..%d", open.Pos, open.End)
+	}
+}
+
+func TestErrorAtInsertedSemicolon(t *testing.T) {
+	p := New(lexer.New("let x\n= 1"))
+	p.Parse_program()
+
+	errs := p.Parse_errors()
+	if len(errs) == 0 {
+		t.Fatalf("expected an error")
+	}
+	if errs[0].Summary() != "expected `=`, found end of line" {
+		t.Errorf("Summary wrong. got=%q", errs[0].Summary())
+	}
+	if errs[0].Pos != 6 || errs[0].End != errs[0].Pos {
+		t.Errorf("inserted semicolon should have an empty span. got=%d..%d", errs[0].Pos, errs[0].End)
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"let x = 5", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n\tx +", true},
+		{"if (x) { y } else {", true},
+		{"add(1,", true},
+		{"add(1, 2", true},
+		{"(1 + 2", true},
+		{"1 +", true},
+		{"let x =", true},
+		{"let", true},
+		{"let = 5 {", false},
+		{"add(1, 2\n3)", false},
+		{"x)", false},
+		{"fn(x) { x } }", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.Parse_program()
+
+		if p.Incomplete() != tt.incomplete {
+			t.Errorf("input %q: Incomplete() = %t, errors=%q", tt.input, p.Incomplete(), p.Errors())
+		}
+		if p.Incomplete() && len(p.Errors()) == 0 {
+			t.Errorf("input %q: incomplete without errors", tt.input)
+		}
+	}
+}
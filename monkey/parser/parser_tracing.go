@@ -2,31 +2,100 @@ package parser
 
 import (
 	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/token"
 	"strings"
 )
 
-var traceLevel int = 0
-
 const traceIdentPlaceholder string = "\t"
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+// With_trace makes the parser write a BEGIN/END line for every Pratt step
+// to w: each parse_expression call, prefix and infix handler, and the
+// precedence comparison that decides whether an operator is consumed.
+func With_trace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.trace_out = w
+	}
+}
+
+func (p *Parser) identLevel() string {
+	return strings.Repeat(traceIdentPlaceholder, max(p.trace_level-1, 0))
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+func (p *Parser) tracePrint(fs string) {
+	fmt.Fprintf(p.trace_out, "%s%s\n", p.identLevel(), fs)
 }
 
-func incIdent() { traceLevel = traceLevel + 1 }
-func decIdent() { traceLevel = traceLevel - 1 }
+func (p *Parser) incIdent() { p.trace_level = p.trace_level + 1 }
+func (p *Parser) decIdent() { p.trace_level = p.trace_level - 1 }
 
-func trace(msg string) string {
-	incIdent()
-	tracePrint("BEGIN " + msg)
+func (p *Parser) trace(msg string) string {
+	p.incIdent()
+	p.tracePrint("BEGIN " + msg)
 	return msg
 }
 
-func untrace(msg string) {
-	tracePrint("END " + msg)
-	decIdent()
+func (p *Parser) untrace(msg string) {
+	p.tracePrint("END " + msg)
+	p.decIdent()
+}
+
+func (p *Parser) call_prefix(fn prefix_Parse_Fn) ast.Expression {
+	if p.trace_out == nil {
+		return fn()
+	}
+	defer p.untrace(p.trace("prefix " + describe_token(p.cur_token)))
+	return fn()
+}
+
+func (p *Parser) call_infix(fn infix_Parse_fn, left ast.Expression) ast.Expression {
+	if p.trace_out == nil {
+		return fn(left)
+	}
+	defer p.untrace(p.trace("infix " + describe_token(p.cur_token)))
+	return fn(left)
+}
+
+func (p *Parser) trace_decision(action string, precedence int) {
+	if p.trace_out == nil {
+		return
+	}
+
+	comparison := "<="
+	if p.peek_precedence() > precedence {
+		comparison = ">"
+	}
+
+	p.incIdent()
+	p.tracePrint(fmt.Sprintf("%s %s: %s %s %s", action, describe_token(p.peek_token),
+		precedence_name(p.peek_precedence()), comparison, precedence_name(precedence)))
+	p.decIdent()
+}
+
+func describe_token(tok token.Token) string {
+	if tok.Type == token.EOF {
+		return "EOF"
+	}
+	return fmt.Sprintf("%s %q", tok.Type, tok.Literal)
+}
+
+func precedence_name(precedence int) string {
+	switch precedence {
+	case LOWEST:
+		return "LOWEST"
+	case EQUALS:
+		return "EQUALS"
+	case LESSGREATER:
+		return "LESSGREATER"
+	case SUM:
+		return "SUM"
+	case PRODUCT:
+		return "PRODUCT"
+	case PREFIX:
+		return "PREFIX"
+	case CALL:
+		return "CALL"
+	}
+	return fmt.Sprint(precedence)
 }
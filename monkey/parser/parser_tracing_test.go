@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"bytes"
+	"monkey/lexer"
+	"sync"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+
+	p := New(lexer.New("-a * b;"), With_trace(&out))
+	p.Parse_program()
+	checkParserErrors(t, p)
+
+	expected := `BEGIN parse_expression(LOWEST) at - "-"
+	BEGIN prefix - "-"
+		BEGIN parse_expression(PREFIX) at IDENT "a"
+			BEGIN prefix IDENT "a"
+			END prefix IDENT "a"
+			stop before * "*": PRODUCT <= PREFIX
+		END parse_expression(PREFIX) at IDENT "a"
+	END prefix - "-"
+	continue with * "*": PRODUCT > LOWEST
+	BEGIN infix * "*"
+		BEGIN parse_expression(PRODUCT) at IDENT "b"
+			BEGIN prefix IDENT "b"
+			END prefix IDENT "b"
+			stop before ; ";": LOWEST <= PRODUCT
+		END parse_expression(PRODUCT) at IDENT "b"
+	END infix * "*"
+	stop before ; ";": LOWEST <= LOWEST
+END parse_expression(LOWEST) at - "-"
+`
+	if out.String() != expected {
+		t.Errorf("trace wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestTraceDisabled(t *testing.T) {
+	p := New(lexer.New("a + b"))
+	p.Parse_program()
+	checkParserErrors(t, p)
+
+	if p.trace_level != 0 {
+		t.Errorf("untraced parser changed trace level to %d", p.trace_level)
+	}
+}
+
+func TestTraceConcurrentParsers(t *testing.T) {
+	inputs := []string{"a + b * c", "fn(x) { x }(1)", "if (a) { b } else { c }", "-(1 + 2)"}
+
+	expected := make([]string, len(inputs))
+	for i, input := range inputs {
+		var out bytes.Buffer
+		New(lexer.New(input), With_trace(&out)).Parse_program()
+		expected[i] = out.String()
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		for i, input := range inputs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				var out bytes.Buffer
+				New(lexer.New(input), With_trace(&out)).Parse_program()
+				if out.String() != expected[i] {
+					t.Errorf("concurrent trace for %q differs:\n%s", input, out.String())
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+// Fix is a quick-fix edit: replace the source between Pos and End with
+// Text. Pos == End inserts Text.
+type Fix struct {
+	Pos  token.Pos
+	End  token.Pos
+	Text string
+}
+
+// check_misspelled_keyword looks at a statement that starts with an
+// identifier for a misspelled keyword, such as "lte x = 5" or "retrun
+// x". It returns the token to parse the statement by, and a keyword to
+// suggest should the statement turn out to have errors anyway.
+//
+// A misspelled let or return is an error when the identifier is followed
+// by a token that could follow the keyword but cannot continue an
+// expression after the identifier: another identifier, or for return
+// anything that starts an expression. An opening parenthesis or minus
+// sign would make a call or a subtraction, so it only counts when set
+// apart by a space, as in "retrun (x)" or "retrun -1". The statement is
+// then parsed as if it were spelled correctly: the returned token is a
+// copy of the identifier with the keyword's type and literal.
+//
+// Anything else, such as "fi x", may well be meant as it is and is left
+// alone, with the keyword returned for note_misspelling.
+func (p *Parser) check_misspelled_keyword() (token.Token, string) {
+	if !p.cur_token_is(token.IDENT) {
+		return p.cur_token, ""
+	}
+	keyword := suggest(p.cur_token.Literal, token.Keywords())
+	if keyword == "" {
+		return p.cur_token, ""
+	}
+	t := token.Lookup_identifier(keyword)
+	switch {
+	case t == token.LET && p.peek_token_is(token.IDENT):
+	case t == token.RETURN && (p.peek_token_is(token.IDENT) || p.peek_starts_return_value()):
+	default:
+		return p.cur_token, keyword
+	}
+
+	msg := fmt.Sprintf("unexpected %s after %s, did you mean %s?",
+		describe_operand(p.peek_token), p.cur_token.Literal, keyword)
+	err := p.error_at(p.cur_token, msg)
+	err.Label = "`" + p.cur_token.Literal + "` is not a keyword"
+	err.Help = "replace `" + p.cur_token.Literal + "` with `" + keyword + "`"
+	if p.cur_token.Pos.Is_valid() {
+		err.Fixes = []Fix{{Pos: err.Pos, End: err.End, Text: keyword}}
+	}
+
+	tok := p.cur_token
+	tok.Type = t
+	tok.Literal = keyword
+	return tok, ""
+}
+
+// misspelling is an identifier that starts a statement and looks like a
+// misspelled keyword.
+type misspelling struct {
+	ident   token.Token
+	keyword string
+}
+
+// note_misspelling adds the suggestion of m's keyword to the first error
+// reported since there were before of them. When there is none and the
+// statement did not end in a semicolon, it is kept for the next one.
+func (p *Parser) note_misspelling(m *misspelling, before int) {
+	if len(p.parse_errors) <= before {
+		if !p.cur_token_is(token.SEMICOLON) {
+			p.misspelling = m
+		}
+		return
+	}
+	err := p.parse_errors[before]
+	err.Related = append(err.Related, Label{
+		Pos: m.ident.Pos,
+		End: token_end(m.ident),
+		Msg: "`" + m.ident.Literal + "` is not a keyword, did you mean `" + m.keyword + "`?",
+	})
+	if err.Help == "" && m.ident.Pos.Is_valid() {
+		err.Help = "replace `" + m.ident.Literal + "` with `" + m.keyword + "`"
+		err.Fixes = []Fix{{Pos: m.ident.Pos, End: token_end(m.ident), Text: m.keyword}}
+	}
+}
+
+// peek_starts_return_value reports whether the peek token starts the
+// value of a misspelled return statement rather than continuing an
+// expression after the current identifier.
+func (p *Parser) peek_starts_return_value() bool {
+	switch p.peek_token.Type {
+	case token.INT, token.TRUE, token.FALSE, token.BANG, token.IF, token.FUNCTION:
+		return true
+	case token.LPAREN, token.MINUS:
+		return p.cur_token.Pos.Is_valid() && token_end(p.cur_token) < p.peek_token.Pos
+	}
+	return false
+}
+
+// describe_operand names tok the way misspelled keyword errors do, e.g.
+// "identifier x" or "number 5".
+func describe_operand(tok token.Token) string {
+	switch tok.Type {
+	case token.IDENT:
+		return "identifier " + tok.Literal
+	case token.INT:
+		return "number " + tok.Literal
+	}
+	return tok.Literal
+}
+
+// suggest returns the candidate closest to word, if it is close enough to
+// be a likely typo: one edit for words of up to four letters, two for
+// longer ones, counting a swap of adjacent letters as one edit. Two-letter
+// words are too short to tell a typo from a different word, so for them
+// only a swap counts, as in "fi". Words that merely extend a keyword, such
+// as "returned", are taken to be meant as they are.
+func suggest(word string, candidates []string) string {
+	if len(word) < 3 {
+		for _, candidate := range candidates {
+			if len(word) == 2 && candidate == string([]byte{word[1], word[0]}) {
+				return candidate
+			}
+		}
+		return ""
+	}
+	limit := 1
+	if len(word) > 4 {
+		limit = 2
+	}
+
+	best, best_distance := "", limit+1
+	for _, candidate := range candidates {
+		if strings.HasPrefix(word, candidate) {
+			continue
+		}
+		if d := edit_distance(word, candidate); d > 0 && d < best_distance {
+			best, best_distance = candidate, d
+		}
+	}
+	return best
+}
+
+// edit_distance is the optimal string alignment distance between a and
+// b: the Levenshtein distance extended with transpositions.
+func edit_distance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+// suggest_closing offers to insert the closing delimiter t that found
+// should have been, right after the last token before it. When there were
+// other errors since the delimiter was opened the closer is more likely
+// lost in those than missing, and no fix is offered.
+func (p *Parser) suggest_closing(err *ParseError, t token.TokenType, found token.Token) {
+	if open := p.delimiters[len(p.delimiters)-1]; len(p.parse_errors) > open.errors+1 {
+		return
+	}
+	at := token_end(p.cur_token)
+	if found.Pos == p.cur_token.Pos {
+		at = found.Pos
+	}
+	if !at.Is_valid() {
+		return
+	}
+	err.Help = "insert " + describe_type(t)
+	err.Fixes = []Fix{{Pos: at, End: at, Text: t.String()}}
+}
+
+// suggest_removing offers to delete a closing delimiter that closes
+// nothing.
+func (p *Parser) suggest_removing(err *ParseError) {
+	closer := p.cur_token.Type
+	for _, open := range p.delimiters {
+		if closes(open.open.Type, closer) {
+			return
+		}
+	}
+	err.Label = "unmatched " + describe_type(closer)
+	err.Help = "remove the unmatched " + describe_type(closer)
+	if err.Pos.Is_valid() {
+		err.Fixes = []Fix{{Pos: err.Pos, End: err.End}}
+	}
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"slices"
+	"testing"
+)
+
+func apply_fixes(file *token.File, source string, errs []*ParseError) string {
+	var fixes []Fix
+	for _, err := range errs {
+		fixes = append(fixes, err.Fixes...)
+	}
+	slices.SortFunc(fixes, func(a, b Fix) int { return int(b.Pos - a.Pos) })
+
+	for _, fix := range fixes {
+		source = source[:file.Offset(fix.Pos)] + fix.Text + source[file.Offset(fix.End):]
+	}
+	return source
+}
+
+func TestSuggestions(t *testing.T) {
+	tests := []struct {
+		input    string
+		program  string
+		message  string
+		help     string
+		repaired string
+	}{
+		{"lte x = 5;", "let x = 5;", "1:1: unexpected identifier x after lte, did you mean let?",
+			"replace `lte` with `let`", "let x = 5;"},
+		{"let y = 1\nretrun y + 1", "let y = 1;return (y + 1);", "2:1: unexpected identifier y after retrun, did you mean return?",
+			"replace `retrun` with `return`", "let y = 1\nreturn y + 1"},
+		{"retrun 5", "return 5;", "1:1: unexpected number 5 after retrun, did you mean return?",
+			"replace `retrun` with `return`", "return 5"},
+		{"retrun (x)", "return x;", "1:1: unexpected ( after retrun, did you mean return?",
+			"replace `retrun` with `return`", "return (x)"},
+		{"retrun -1", "return (-1);", "1:1: unexpected - after retrun, did you mean return?",
+			"replace `retrun` with `return`", "return -1"},
+		{"add(1, 2", "", "1:9: expected next token to be ), got EOF instead",
+			"insert `)`", "add(1, 2)"},
+		{"if (x) {\n\ty\n", "if (x) { y }", "3:1: expected next token to be }, got EOF instead",
+			"insert `}`", "if (x) {\n\ty\n}"},
+		{"x)", "x", "1:2: no prefix parse function for ) found",
+			"remove the unmatched `)`", "x"},
+		{"f(1, )", "", "1:6: no prefix parse function for ) found", "", "f(1, )"},
+	}
+
+	for _, tt := range tests {
+		fset := token.New_file_set()
+		file := fset.Add_file("", len(tt.input))
+		p := New(lexer.New(tt.input, lexer.With_file(file)))
+		program := p.Parse_program()
+
+		errs := p.Parse_errors()
+		if len(errs) == 0 {
+			t.Errorf("input %q: expected errors", tt.input)
+			continue
+		}
+		if errs[0].Error() != tt.message || errs[0].Help != tt.help {
+			t.Errorf("input %q: wrong error. expected=%q (%s), got=%q (%s)",
+				tt.input, tt.message, tt.help, errs[0].Error(), errs[0].Help)
+		}
+		if program.String() != tt.program {
+			t.Errorf("input %q: wrong recovery. expected=%q, got=%q", tt.input, tt.program, program.String())
+		}
+		if repaired := apply_fixes(file, tt.input, errs); repaired != tt.repaired {
+			t.Errorf("input %q: wrong fixes. expected=%q, got=%q", tt.input, tt.repaired, repaired)
+		}
+	}
+}
+
+// An identifier that looks like a keyword other than let or return may
+// be meant as it is, so it is only suggested along with an error the
+// statement has anyway.
+func TestMisspelledKeywordsWithoutErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		program string
+	}{
+		{"fi x", "fi;x"},
+		{"tru x", "tru;x"},
+		{"let fals = 1; fals\nx", "let fals = 1;fals;x"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.Parse_program()
+		checkParserErrors(t, p)
+		if program.String() != tt.program {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.program, program.String())
+		}
+	}
+}
+
+func TestMisspelledKeywordNote(t *testing.T) {
+	p := New(lexer.New("fi (x) { y }"))
+	p.Parse_program()
+
+	errs := p.Parse_errors()
+	if len(errs) == 0 {
+		t.Fatalf("expected an error")
+	}
+	if len(errs[0].Related) != 1 || errs[0].Related[0].Msg != "`fi` is not a keyword, did you mean `if`?" ||
+		errs[0].Related[0].Pos != 1 || errs[0].Related[0].End != 3 {
+		t.Errorf("wrong note. got=%+v", errs[0].Related)
+	}
+	if errs[0].Help != "replace `fi` with `if`" || len(errs[0].Fixes) != 1 || errs[0].Fixes[0].Text != "if" {
+		t.Errorf("wrong help. got=%q %+v", errs[0].Help, errs[0].Fixes)
+	}
+}
+
+func TestNoSuggestions(t *testing.T) {
+	inputs := []string{"a in b", "lettuce x", "returned value", "x y", "f(x) if", "retrun(x)", "retrun-1", "lte 5"}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		p.Parse_program()
+
+		for _, err := range p.Parse_errors() {
+			if err.Help != "" {
+				t.Errorf("input %q: unexpected suggestion %q", input, err.Help)
+			}
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"let", "let", 0},
+		{"lte", "let", 1},
+		{"retrun", "return", 1},
+		{"retun", "return", 1},
+		{"reutrn", "return", 1},
+		{"rtn", "return", 3},
+		{"", "fn", 2},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := edit_distance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("edit_distance(%q, %q) wrong. expected=%d, got=%d", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/astdump"
+	"monkey/lexer"
+	"monkey/token"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *session, arg string)
+	// files marks the commands Options.No_files disables.
+	files bool
+}
+
+// commands is filled in by init, as :help refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{":tokens", "<code>", "show the tokens the lexer produces for code", (*session).tokens, false},
+		{":ast", "<code>", "show the syntax tree of code", (*session).ast, false},
+		{":dot", "<code>", "show the syntax tree of code as a Graphviz graph", (*session).dot, false},
+		{":trace", "", "toggle tracing of the parser's decisions", (*session).trace, false},
+		{":load", "<file>", "run the program in file as one entry", (*session).load, true},
+		{":save", "<file>", "write the entries of this session to file", (*session).save, true},
+		{":reset", "", "forget the entries and names of this session", (*session).reset, false},
+		{":help", "", "list the commands", (*session).help, false},
+	}
+}
+
+func (s *session) run_command(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if c.files && s.opts.No_files {
+			fmt.Fprintf(s.out, "%s is not available over the network\n", c.name)
+			return
+		}
+		if c.usage != "" && arg == "" {
+			fmt.Fprintf(s.out, "usage: %s %s\n", c.name, c.usage)
+			return
+		}
+		c.run(s, arg)
+		return
+	}
+	fmt.Fprintf(s.out, "unknown command %s, try :help\n", name)
+}
+
+func (s *session) tokens(arg string) {
+	file := token.New_file_set().Add_file("", len(arg))
+	for tok := range lexer.Tokens(arg, lexer.With_file(file)) {
+		fmt.Fprintf(s.out, "%-6s %-9s %q\n", file.Position(tok.Pos), tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) ast(arg string) {
+	s.dump(arg, astdump.Sexpr)
+}
+
+func (s *session) dot(arg string) {
+	s.dump(arg, astdump.Dot)
+}
+
+func (s *session) dump(arg string, format func(ast.Node) string) {
+	p, file := s.new_parser(arg, "")
+	program := p.Parse_program()
+
+	if len(p.Errors()) != 0 {
+		s.print_parser_errors(arg, file, p.Parse_errors())
+		return
+	}
+	io.WriteString(s.out, format(program))
+}
+
+func (s *session) trace(string) {
+	s.opts.Trace_parse = !s.opts.Trace_parse
+	if s.opts.Trace_parse {
+		io.WriteString(s.out, "parser tracing on\n")
+	} else {
+		io.WriteString(s.out, "parser tracing off\n")
+	}
+}
+
+func (s *session) load(filename string) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	input := string(source)
+	p, file := s.new_parser(input, filename)
+	program := p.Parse_program()
+
+	if len(p.Errors()) != 0 {
+		s.print_parser_errors(input, file, p.Parse_errors())
+		return
+	}
+	s.history = append(s.history, strings.TrimRight(input, "\n"))
+	s.bind(program)
+	s.echo(program)
+}
+
+func (s *session) save(filename string) {
+	var out strings.Builder
+	for _, entry := range s.history {
+		out.WriteString(entry + "\n")
+	}
+
+	if err := os.WriteFile(filename, []byte(out.String()), 0644); err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d entries to %s\n", len(s.history), filename)
+}
+
+func (s *session) reset(string) {
+	s.history, s.names = nil, nil
+	io.WriteString(s.out, "session reset\n")
+}
+
+func (s *session) help(string) {
+	for _, c := range commands {
+		fmt.Fprintf(s.out, "%-22s %s\n", strings.TrimSpace(c.name+" "+c.usage), c.help)
+	}
+}
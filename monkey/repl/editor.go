@@ -0,0 +1,422 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/highlight"
+	"monkey/term"
+	"os"
+	"slices"
+	"strings"
+)
+
+// MAX_HISTORY is how many lines of history are kept, in memory and in the
+// history file.
+const MAX_HISTORY = 1000
+
+// err_interrupted is returned by read_line when the user presses Ctrl-C.
+var err_interrupted = errors.New("interrupted")
+
+// line_source reads the lines of a session, showing prompt first.
+type line_source interface {
+	read_line(prompt string) (string, error)
+}
+
+// scanner_source reads lines without editing, for input that is not a
+// terminal.
+type scanner_source struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (s *scanner_source) read_line(prompt string) (string, error) {
+	io.WriteString(s.out, prompt)
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return s.scanner.Text(), nil
+}
+
+// editor reads lines from a terminal in raw mode, with Emacs-style cursor
+// movement, history, reverse search and tab completion.
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// fd is the terminal put into raw mode while a line is read, or -1.
+	fd int
+
+	history      []string
+	history_file string
+	// complete returns the words Tab completes to.
+	complete func() []string
+	// color highlights the line as it is typed.
+	color bool
+
+	prompt        string
+	buf           []rune
+	pos           int
+	history_index int
+	saved         []rune
+}
+
+func new_editor(in io.Reader, out io.Writer, fd int, history_file string, complete func() []string) *editor {
+	e := &editor{
+		in:           bufio.NewReader(in),
+		out:          out,
+		fd:           fd,
+		history_file: history_file,
+		complete:     complete,
+	}
+	e.load_history()
+	return e
+}
+
+func (e *editor) read_line(prompt string) (string, error) {
+	if e.fd >= 0 {
+		restore, err := term.Make_raw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+	e.history_index, e.saved = len(e.history), nil
+	e.refresh()
+
+	last_tab := false
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if len(e.buf) > 0 {
+				return e.submit(), nil
+			}
+			return "", err
+		}
+
+		tab := false
+		switch r {
+		case '\r', '\n':
+			return e.submit(), nil
+		case ctrl('C'):
+			io.WriteString(e.out, "^C\r\n")
+			return "", err_interrupted
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.delete(e.pos, e.pos+1)
+		case ctrl('A'):
+			e.pos = 0
+		case ctrl('E'):
+			e.pos = len(e.buf)
+		case ctrl('B'):
+			e.pos = max(e.pos-1, 0)
+		case ctrl('F'):
+			e.pos = min(e.pos+1, len(e.buf))
+		case ctrl('H'), 127:
+			e.delete(e.pos-1, e.pos)
+		case ctrl('K'):
+			e.delete(e.pos, len(e.buf))
+		case ctrl('U'):
+			e.delete(0, e.pos)
+		case ctrl('W'):
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.delete(start, e.pos)
+		case ctrl('L'):
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'):
+			e.history_move(-1)
+		case ctrl('N'):
+			e.history_move(1)
+		case ctrl('R'):
+			if e.search() {
+				return e.submit(), nil
+			}
+		case '\t':
+			e.complete_word(last_tab)
+			tab = true
+		case 27:
+			e.escape()
+		default:
+			if r >= ' ' {
+				e.insert(string(r))
+			}
+		}
+		last_tab = tab
+		e.refresh()
+	}
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+func (e *editor) submit() string {
+	io.WriteString(e.out, "\r\n")
+	line := string(e.buf)
+	e.add_history(line)
+	return line
+}
+
+// escape handles the rest of an escape sequence such as "\x1b[A".
+func (e *editor) escape() {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != '[' && r != 'O' {
+		return
+	}
+
+	var digits string
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return
+		}
+		if r < '0' || r > '9' {
+			break
+		}
+		digits += string(r)
+	}
+
+	switch {
+	case r == 'A':
+		e.history_move(-1)
+	case r == 'B':
+		e.history_move(1)
+	case r == 'C':
+		e.pos = min(e.pos+1, len(e.buf))
+	case r == 'D':
+		e.pos = max(e.pos-1, 0)
+	case r == 'H', r == '~' && (digits == "1" || digits == "7"):
+		e.pos = 0
+	case r == 'F', r == '~' && (digits == "4" || digits == "8"):
+		e.pos = len(e.buf)
+	case r == '~' && digits == "3":
+		e.delete(e.pos, e.pos+1)
+	}
+}
+
+func (e *editor) insert(s string) {
+	text := []rune(s)
+	e.buf = slices.Insert(e.buf, e.pos, text...)
+	e.pos += len(text)
+}
+
+// delete removes the runes from start up to end, clamped to the line.
+func (e *editor) delete(start, end int) {
+	start, end = max(start, 0), min(end, len(e.buf))
+	if start >= end {
+		return
+	}
+	e.buf = slices.Delete(e.buf, start, end)
+	if e.pos > end {
+		e.pos -= end - start
+	} else if e.pos > start {
+		e.pos = start
+	}
+}
+
+// refresh redraws the prompt and the line and puts the cursor in place.
+func (e *editor) refresh() {
+	line := string(e.buf)
+	if e.color {
+		line = highlight.ANSI(line)
+	}
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, line)
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// history_move replaces the line with an older (delta -1) or newer entry.
+// The line being typed is kept and comes back past the newest entry.
+func (e *editor) history_move(delta int) {
+	index := e.history_index + delta
+	if index < 0 || index > len(e.history) {
+		return
+	}
+	if e.history_index == len(e.history) {
+		e.saved = slices.Clone(e.buf)
+	}
+
+	e.history_index = index
+	if index == len(e.history) {
+		e.buf = e.saved
+	} else {
+		e.buf = []rune(e.history[index])
+	}
+	e.pos = len(e.buf)
+}
+
+// search runs an incremental reverse search of the history. Enter submits
+// the match, which search reports by returning true; any other control key
+// keeps it for editing, and Ctrl-G or Ctrl-C restores the line.
+func (e *editor) search() bool {
+	var query []rune
+	match, failing := -1, false
+
+	// find moves match to the newest entry at or before from that
+	// contains the query, if there is one.
+	find := func(from int) {
+		for i := min(from, len(e.history)-1); i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				match, failing = i, false
+				return
+			}
+		}
+		failing = true
+	}
+
+	for {
+		found, status := "", "reverse-i-search"
+		if match >= 0 {
+			found = e.history[match]
+		}
+		if failing {
+			status = "failing " + status
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", status, string(query), found)
+
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false
+		}
+
+		switch {
+		case r == ctrl('R'):
+			if len(query) > 0 {
+				find(match - 1)
+			}
+		case r == ctrl('H') || r == 127:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = -1
+				find(len(e.history) - 1)
+			}
+		case r == ctrl('G') || r == ctrl('C'):
+			return false
+		case r >= ' ':
+			query = append(query, r)
+			if match < 0 {
+				find(len(e.history) - 1)
+			} else {
+				find(match)
+			}
+		default:
+			if match >= 0 {
+				e.buf = []rune(e.history[match])
+				e.pos = len(e.buf)
+			}
+			if r == 27 {
+				e.escape()
+			}
+			return (r == '\r' || r == '\n') && match >= 0
+		}
+	}
+}
+
+// complete_word completes the identifier before the cursor as far as the
+// candidates agree. When they do not, a second Tab lists them.
+func (e *editor) complete_word(list bool) {
+	start := e.pos
+	for start > 0 && is_word_rune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" || e.complete == nil {
+		return
+	}
+
+	var matches []string
+	for _, word := range e.complete() {
+		if strings.HasPrefix(word, prefix) && !slices.Contains(matches, word) {
+			matches = append(matches, word)
+		}
+	}
+	slices.Sort(matches)
+
+	if len(matches) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	common := matches[0]
+	for _, word := range matches[1:] {
+		for !strings.HasPrefix(word, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(prefix) {
+		e.insert(common[len(prefix):])
+		return
+	}
+	if len(matches) > 1 {
+		if list {
+			fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(matches, "  "))
+		} else {
+			io.WriteString(e.out, "\a")
+		}
+	}
+}
+
+func is_word_rune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
+
+func (e *editor) load_history() {
+	if e.history_file == "" {
+		return
+	}
+	data, err := os.ReadFile(e.history_file)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+
+	// The file is only appended to while editing, so trim it here.
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[len(e.history)-MAX_HISTORY:]
+		os.WriteFile(e.history_file, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+}
+
+// add_history records line, unless it is empty or repeats the last entry,
+// and appends it to the history file.
+func (e *editor) add_history(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[1:]
+	}
+
+	if e.history_file == "" {
+		return
+	}
+	f, err := os.OpenFile(e.history_file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
@@ -0,0 +1,218 @@
+package repl
+
+import (
+	"io"
+	"monkey/highlight"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// read_lines feeds keys to an editor and returns the lines it reads, up
+// to the first error.
+func read_lines(keys string, history_file string, complete func() []string) ([]string, error, string) {
+	var out strings.Builder
+	e := new_editor(strings.NewReader(keys), &out, -1, history_file, complete)
+
+	var lines []string
+	for {
+		line, err := e.read_line(PROMPT)
+		if err != nil {
+			return lines, err, out.String()
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"let x = 5\r", "let x = 5"},
+		{"let xy\x7f = 5\n", "let x = 5"},
+		{"x = 5\x01let \x05;\r", "let x = 5;"},
+		{"let  = 5\x1b[D\x1b[D\x1b[D\x1b[Dx\r", "let x = 5"},
+		{"1 + 2\x02\x02\x02\x02\x0b3\r", "13"},
+		{"foo bar\x17baz\r", "foo baz"},
+		{"abc\x1b[H\x1b[3~\x1b[F!\r", "bc!"},
+		{"let x\x15y\r", "y"},
+	}
+
+	for _, tt := range tests {
+		lines, _, _ := read_lines(tt.keys, "", nil)
+		if len(lines) != 1 || lines[0] != tt.expected {
+			t.Errorf("keys %q: wrong line. expected=%q, got=%q", tt.keys, tt.expected, lines)
+		}
+	}
+}
+
+func TestControlKeys(t *testing.T) {
+	lines, err, out := read_lines("x\r\x03", "", nil)
+	if len(lines) != 1 || lines[0] != "x" || err != err_interrupted {
+		t.Errorf("Ctrl-C did not interrupt. got=%q, %v", lines, err)
+	}
+	if !strings.HasSuffix(out, "^C\r\n") {
+		t.Errorf("Ctrl-C not echoed. got=%q", out)
+	}
+
+	lines, err, _ = read_lines("x\r\x04", "", nil)
+	if len(lines) != 1 || err != io.EOF {
+		t.Errorf("Ctrl-D did not end the input. got=%q, %v", lines, err)
+	}
+
+	lines, err, _ = read_lines("half", "", nil)
+	if len(lines) != 1 || lines[0] != "half" || err != io.EOF {
+		t.Errorf("line cut off by the end of input was lost. got=%q, %v", lines, err)
+	}
+
+	lines, err, _ = read_lines("ab\x01\x04", "", nil)
+	if len(lines) != 1 || lines[0] != "b" || err != io.EOF {
+		t.Errorf("Ctrl-D did not delete or ended the line early. got=%q, %v", lines, err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+
+	keys := "first\rsecond\r\r\x1b[A\x1b[A\r\x10\x10\x10\x0e!\rtyped\x1b[A\x1b[B\r"
+	lines, _, _ := read_lines(keys, file, nil)
+	expected := []string{"first", "second", "", "first", "second!", "typed"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong lines.\nexpected=%q\ngot=%q", expected, lines)
+	}
+
+	saved, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != "first\nsecond\nfirst\nsecond!\ntyped\n" {
+		t.Errorf("wrong history file. got=%q", saved)
+	}
+
+	lines, _, _ = read_lines("\x10\x10\r", file, nil)
+	if len(lines) != 1 || lines[0] != "second!" {
+		t.Errorf("history was not kept. got=%q", lines)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	var entries strings.Builder
+	for i := range MAX_HISTORY + 10 {
+		entries.WriteString(strings.Repeat("x", i+1) + "\n")
+	}
+	if err := os.WriteFile(file, []byte(entries.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := new_editor(strings.NewReader(""), io.Discard, -1, file, nil)
+	if len(e.history) != MAX_HISTORY || len(e.history[0]) != 11 {
+		t.Errorf("history not trimmed. got %d entries", len(e.history))
+	}
+	saved, _ := os.ReadFile(file)
+	if strings.Count(string(saved), "\n") != MAX_HISTORY {
+		t.Errorf("history file not trimmed. got %d lines", strings.Count(string(saved), "\n"))
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	dir := t.TempDir()
+	history_file := func() string {
+		file := filepath.Join(dir, "history")
+		os.WriteFile(file, []byte("let a = 1\nlet b = 2\nputs(a)\n"), 0600)
+		return file
+	}
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12let\r", "let b = 2"},
+		{"\x12let\x12\r", "let a = 1"},
+		{"\x12a\x12\r", "let a = 1"},
+		{"\x12b\x05;\r", "let b = 2;"},
+		{"x\x12zzz\x07\r", "x"},
+		{"\x12put\x7f\x7f\x7flet\r", "let b = 2"},
+	}
+
+	for _, tt := range tests {
+		lines, _, out := read_lines(tt.keys, history_file(), nil)
+		if len(lines) == 0 || lines[0] != tt.expected {
+			t.Errorf("keys %q: wrong line. expected=%q, got=%q", tt.keys, tt.expected, lines)
+		}
+		if !strings.Contains(out, "(reverse-i-search)`") {
+			t.Errorf("keys %q: search prompt not shown. got=%q", tt.keys, out)
+		}
+	}
+
+	_, _, out := read_lines("\x12zzz\x07\r", history_file(), nil)
+	if !strings.Contains(out, "(failing reverse-i-search)`zzz'") {
+		t.Errorf("failed search not shown. got=%q", out)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	complete := func() []string { return []string{"let", "return", "result", "results", "fn"} }
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"le\t x\r", "let x"},
+		{"f\t(x)\r", "fn(x)"},
+		{"re\t\r", "re"},
+		{"res\t\r", "result"},
+		{"x + \t\r", "x + "},
+		{"zz\t\r", "zz"},
+	}
+
+	for _, tt := range tests {
+		lines, _, _ := read_lines(tt.keys, "", complete)
+		if len(lines) != 1 || lines[0] != tt.expected {
+			t.Errorf("keys %q: wrong line. expected=%q, got=%q", tt.keys, tt.expected, lines)
+		}
+	}
+
+	_, _, out := read_lines("re\t\t\r", "", complete)
+	if !strings.Contains(out, "\r\nresult  results  return\r\n") {
+		t.Errorf("second Tab did not list candidates. got=%q", out)
+	}
+}
+
+func TestSessionCompletions(t *testing.T) {
+	s := &session{out: io.Discard}
+	for _, entry := range []string{"let counter = 0; let total = 1", "let counter = 2", "1 + 1"} {
+		p, _ := s.new_parser(entry, "")
+		s.bind(p.Parse_program())
+	}
+
+	completions := s.completions()
+	for _, word := range []string{"let", "return", "fn", "counter", "total"} {
+		if !strings.Contains(" "+strings.Join(completions, " ")+" ", " "+word+" ") {
+			t.Errorf("completions have no %q. got=%q", word, completions)
+		}
+	}
+	if strings.Count(strings.Join(completions, " "), "counter") != 1 {
+		t.Errorf("counter completed twice. got=%q", completions)
+	}
+
+	s.run_command(":reset")
+	if len(s.names) != 0 {
+		t.Errorf(":reset kept names. got=%q", s.names)
+	}
+}
+
+func TestHighlightedLine(t *testing.T) {
+	var out strings.Builder
+	e := new_editor(strings.NewReader("let x\x01\r"), &out, -1, "", nil)
+	e.color = true
+
+	if line, _ := e.read_line(PROMPT); line != "let x" {
+		t.Errorf("wrong line. got=%q", line)
+	}
+	if !strings.Contains(out.String(), PROMPT+highlight.ANSI("let x")+"\x1b[K\x1b[5D") {
+		t.Errorf("line not highlighted or cursor misplaced. got=%q", out.String())
+	}
+}
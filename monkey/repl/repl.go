@@ -2,42 +2,144 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/highlight"
 	"monkey/lexer"
 	"monkey/parser"
+	"monkey/term"
+	"monkey/token"
+	"os"
+	"slices"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT asks for the next line of an incomplete entry. An
+// empty line ends the entry and reports its errors.
+const CONTINUATION_PROMPT = ".. "
+
+type Options struct {
+	// Trace_parse writes the parser's trace to the output before each result.
+	Trace_parse bool
+	// Color highlights parse errors and the code typed and echoed with
+	// ANSI colors.
+	Color bool
+	// History_file keeps the line editor's history between sessions.
+	History_file string
+	// No_files disables the commands that read or write files, :load and
+	// :save. Server always sets it.
+	No_files bool
+}
+
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	Start_with(in, out, Options{})
+}
+
+// session is the state of one REPL: its options, which commands may
+// change, the entries that parsed, which :save writes out, and the names
+// they bound, which Tab completes.
+type session struct {
+	out     io.Writer
+	opts    Options
+	history []string
+	names   []string
+}
+
+// Start_with runs a REPL. When in is a terminal, lines are read with a
+// line editor; otherwise they are scanned as they come.
+func Start_with(in io.Reader, out io.Writer, opts Options) {
+	s := &session{out: out, opts: opts}
+	lines := s.new_line_source(in)
+	var pending []string
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if len(pending) > 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+		line, err := lines.read_line(prompt)
+		if err == err_interrupted {
+			pending = nil
+			continue
+		}
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if len(pending) == 0 && strings.HasPrefix(line, ":") {
+			s.run_command(line)
+			continue
+		}
+
+		pending = append(pending, line)
+		input := strings.Join(pending, "\n")
+		p, file := s.new_parser(input, "")
 
 		program := p.Parse_program()
 
+		if p.Incomplete() && line != "" {
+			continue
+		}
+		pending = nil
+
 		if len(p.Errors()) != 0 {
-			print_parser_errors(out, p.Errors())
+			s.print_parser_errors(input, file, p.Parse_errors())
 			continue
 
 		}
-		io.WriteString(out, program.String())
-		io.WriteString(out, "\n")
+		if strings.TrimSpace(input) != "" {
+			s.history = append(s.history, input)
+		}
+		s.bind(program)
+		s.echo(program)
+	}
+}
+
+func (s *session) new_line_source(in io.Reader) line_source {
+	if f, ok := in.(*os.File); ok && term.Is_terminal(int(f.Fd())) {
+		e := new_editor(f, s.out, int(f.Fd()), s.opts.History_file, s.completions)
+		e.color = s.opts.Color
+		return e
 	}
+	return &scanner_source{scanner: bufio.NewScanner(in), out: s.out}
 }
 
-func print_parser_errors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// bind remembers the names program's let statements bind.
+func (s *session) bind(program *ast.Program) {
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.Let_statement); ok && !slices.Contains(s.names, let.Name.Value) {
+			s.names = append(s.names, let.Name.Value)
+		}
 	}
+}
+
+func (s *session) completions() []string {
+	return append(token.Keywords(), s.names...)
+}
+
+// echo prints program back, highlighted when colors are on.
+func (s *session) echo(program *ast.Program) {
+	if s.opts.Color {
+		io.WriteString(s.out, highlight.ANSI(program.String()))
+	} else {
+		io.WriteString(s.out, program.String())
+	}
+	io.WriteString(s.out, "\n")
+}
+
+func (s *session) new_parser(input string, filename string) (*parser.Parser, *token.File) {
+	var options []parser.Option
+	if s.opts.Trace_parse {
+		options = append(options, parser.With_trace(s.out))
+	}
+	file := token.New_file_set().Add_file(filename, len(input))
+	return parser.New(lexer.New(input, lexer.With_file(file)), options...), file
+}
 
+func (s *session) print_parser_errors(input string, file *token.File, errors []*parser.ParseError) {
+	printer := diag.New(file, input)
+	printer.Color = s.opts.Color
+	printer.Fprint(s.out, errors)
 }
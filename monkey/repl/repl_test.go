@@ -0,0 +1,153 @@
+package repl
+
+import (
+	"monkey/highlight"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(input string) string {
+	var out strings.Builder
+	Start(strings.NewReader(input), &out)
+	return out.String()
+}
+
+func TestMultiLineEntry(t *testing.T) {
+	input := "let add = fn(a, b) {\n\treturn a +\n\t\tb\n}\nadd(1,\n2)\n"
+	expected := ">> .. .. .. let add = fn(a, b) { return (a + b); };\n" +
+		">> .. add(1, 2)\n" +
+		">> "
+
+	if got := run(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestIncompleteEntryEndedByEmptyLine(t *testing.T) {
+	got := run("if (x) {\n\n1\n")
+
+	if !strings.HasPrefix(got, ">> .. error: expected `}`, found end of input\n") {
+		t.Errorf("empty line did not end the entry. got=%q", got)
+	}
+	if !strings.HasSuffix(got, ">> 1\n>> ") {
+		t.Errorf("REPL did not start a new entry. got=%q", got)
+	}
+}
+
+func TestSyntaxErrorIsNotContinued(t *testing.T) {
+	got := run("let = {\nx\n")
+
+	if !strings.HasPrefix(got, ">> error: ") || !strings.HasSuffix(got, ">> x\n>> ") {
+		t.Errorf("syntax error asked for more input. got=%q", got)
+	}
+}
+
+func TestTokensCommand(t *testing.T) {
+	expected := ">> 1:1    LET       \"let\"\n" +
+		"1:5    IDENT     \"x\"\n" +
+		"1:7    =         \"=\"\n" +
+		"1:9    INT       \"5\"\n" +
+		">> "
+
+	if got := run(":tokens let x = 5\n"); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestAstCommand(t *testing.T) {
+	expected := ">> (program\n  (expr\n    (prefix -\n      (ident a))))\n>> "
+
+	if got := run(":ast -a\n"); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, got)
+	}
+	if got := run(":ast let\n"); !strings.HasPrefix(got, ">> error: expected an identifier") {
+		t.Errorf(":ast did not report errors. got=%q", got)
+	}
+}
+
+func TestTraceCommand(t *testing.T) {
+	got := run(":trace\n1\n:trace\n2\n")
+
+	traced, untraced, _ := strings.Cut(got, "parser tracing off")
+	if !strings.Contains(traced, "parser tracing on\n>> BEGIN parse_expression(LOWEST)") {
+		t.Errorf("tracing was not turned on. got=%q", traced)
+	}
+	if strings.Contains(untraced, "BEGIN") {
+		t.Errorf("tracing was not turned off. got=%q", untraced)
+	}
+}
+
+func TestSaveLoadReset(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "session.mk")
+	empty := filepath.Join(dir, "empty.mk")
+
+	input := "let a = 1\nlet f = fn(x) {\n\tx\n}\nlet = 2\n" +
+		":save " + saved + "\n:reset\n:save " + empty + "\n:load " + saved + "\n"
+	got := run(input)
+
+	source, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(source) != "let a = 1\nlet f = fn(x) {\n\tx\n}\n" {
+		t.Errorf("saved session wrong. got=%q", source)
+	}
+	if source, _ := os.ReadFile(empty); len(source) != 0 {
+		t.Errorf(":reset did not forget the session. saved=%q", source)
+	}
+
+	for _, part := range []string{
+		"saved 2 entries to " + saved + "\n",
+		"session reset\n",
+		"saved 0 entries to " + empty + "\n",
+		">> let a = 1;let f = fn(x) { x };\n>> ",
+	} {
+		if !strings.Contains(got, part) {
+			t.Errorf("output has no %q. got=%q", part, got)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "broken.mk")
+	if err := os.WriteFile(file, []byte("let x = 1\nlet = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := run(":load " + file + "\n:load\n:load " + file + ".missing\n")
+	for _, part := range []string{
+		" --> " + file + ":2:5\n",
+		"usage: :load <file>\n",
+		"no such file or directory\n",
+	} {
+		if !strings.Contains(got, part) {
+			t.Errorf("output has no %q. got=%q", part, got)
+		}
+	}
+}
+
+func TestHelpAndUnknownCommands(t *testing.T) {
+	got := run(":help\n:frobnicate\n")
+
+	for _, c := range commands {
+		if !strings.Contains(got, c.name) {
+			t.Errorf(":help does not list %s", c.name)
+		}
+	}
+	if !strings.Contains(got, "unknown command :frobnicate, try :help\n") {
+		t.Errorf("unknown command not reported. got=%q", got)
+	}
+}
+
+func TestColorEcho(t *testing.T) {
+	var out strings.Builder
+	Start_with(strings.NewReader("let x = 5\n"), &out, Options{Color: true})
+
+	expected := ">> " + highlight.ANSI("let x = 5;") + "\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
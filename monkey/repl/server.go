@@ -0,0 +1,167 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// farewell_timeout bounds how long a connection is kept open to tell the
+// client why it is being closed.
+const farewell_timeout = time.Second
+
+// Server runs an independent REPL session for each connection it accepts,
+// over TCP, a Unix socket or any other net.Listener.
+type Server struct {
+	// Options configures every session. The connections are not
+	// terminals, so there is no line editing and no history file, and
+	// No_files is always set so that clients cannot reach the server's
+	// files.
+	Options Options
+	// Max_connections is how many sessions may run at once; further
+	// connections are told so and closed. Zero means no limit.
+	Max_connections int
+	// Idle_timeout ends a session whose client has neither sent input nor
+	// read output for that long. Zero means sessions never time out.
+	Idle_timeout time.Duration
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// Serve runs a Server with default settings on l.
+func Serve(ctx context.Context, l net.Listener) error {
+	return (&Server{}).Serve(ctx, l)
+}
+
+// Serve accepts connections on l until ctx is done. It then closes l and
+// ends every session after the entry it is working on. Serve returns once
+// all sessions are over: nil when ctx is done, otherwise the error from
+// Accept.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	var sessions sync.WaitGroup
+	defer sessions.Wait()
+
+	stop := context.AfterFunc(ctx, func() {
+		l.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for conn := range s.conns {
+			// Wake up the sessions waiting for input, and give those
+			// writing output a little time to finish.
+			conn.SetReadDeadline(time.Now())
+			conn.SetWriteDeadline(time.Now().Add(farewell_timeout))
+		}
+	})
+	defer stop()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		sessions.Add(1)
+		if !s.add(conn) {
+			go func() {
+				defer sessions.Done()
+				farewell(conn, "too many connections, try again later\n")
+				conn.Close()
+			}()
+			continue
+		}
+		go func() {
+			defer sessions.Done()
+			defer s.remove(conn)
+			s.serve_conn(ctx, conn)
+		}()
+	}
+}
+
+// add registers conn, unless the server is at its connection limit.
+func (s *Server) add(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Max_connections > 0 && len(s.conns) >= s.Max_connections {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]bool)
+	}
+	s.conns[conn] = true
+	return true
+}
+
+func (s *Server) remove(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+	conn.Close()
+}
+
+func (s *Server) serve_conn(ctx context.Context, conn net.Conn) {
+	c := &session_conn{conn: conn, timeout: s.Idle_timeout, done: ctx.Done()}
+	opts := s.Options
+	opts.No_files = true
+	Start_with(c, c, opts)
+
+	switch {
+	case ctx.Err() != nil:
+		farewell(conn, "\nserver shutting down\n")
+	case errors.Is(c.err, os.ErrDeadlineExceeded):
+		farewell(conn, "\nidle timeout, closing session\n")
+	}
+}
+
+// farewell tells the client why conn is about to be closed, unless it
+// does not read it in time.
+func farewell(conn net.Conn, msg string) {
+	conn.SetWriteDeadline(time.Now().Add(farewell_timeout))
+	io.WriteString(conn, msg)
+}
+
+// session_conn renews the deadlines of a connection before each read and
+// write, and reads nothing more once done is closed.
+type session_conn struct {
+	conn    net.Conn
+	timeout time.Duration
+	done    <-chan struct{}
+	// err is the last error reading from conn.
+	err error
+}
+
+func (c *session_conn) Read(p []byte) (int, error) {
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	// Checked after renewing the deadline, which could otherwise undo the
+	// one set by Serve to stop the session.
+	select {
+	case <-c.done:
+		return 0, io.EOF
+	default:
+	}
+
+	n, err := c.conn.Read(p)
+	c.err = err
+	return n, err
+}
+
+func (c *session_conn) Write(p []byte) (int, error) {
+	select {
+	case <-c.done:
+		// Keep the deadline Serve set when stopping the session.
+	default:
+		if c.timeout > 0 {
+			c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+		}
+	}
+	return c.conn.Write(p)
+}
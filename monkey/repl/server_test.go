@@ -0,0 +1,282 @@
+package repl
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipe_listener hands out the server ends of in-memory connections made
+// with dial.
+type pipe_listener struct {
+	conns chan net.Conn
+	close sync.Once
+	done  chan struct{}
+}
+
+func new_pipe_listener() *pipe_listener {
+	return &pipe_listener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipe_listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipe_listener) Close() error {
+	l.close.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipe_listener) Addr() net.Addr {
+	return pipe_addr{}
+}
+
+func (l *pipe_listener) dial() (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+type pipe_addr struct{}
+
+func (pipe_addr) Network() string { return "pipe" }
+func (pipe_addr) String() string  { return "pipe" }
+
+// serve runs s on l in the background. The returned function stops it and
+// returns the error from Serve.
+func serve(t *testing.T, s *Server, l net.Listener) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- s.Serve(ctx, l) }()
+
+	return func() error {
+		cancel()
+		select {
+		case err := <-result:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Serve did not return after shutdown")
+			return nil
+		}
+	}
+}
+
+// expect reads from conn until the output so far ends with want.
+func expect(t *testing.T, conn net.Conn, want string) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var got []byte
+	buf := make([]byte, 256)
+	for !strings.HasSuffix(string(got), want) {
+		n, err := conn.Read(buf)
+		got = append(got, buf[:n]...)
+		if err != nil {
+			t.Fatalf("expected output ending in %q, got %q: %v", want, got, err)
+		}
+	}
+	return string(got)
+}
+
+func send(t *testing.T, conn net.Conn, input string) {
+	t.Helper()
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, input); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeIndependentSessions(t *testing.T) {
+	l := new_pipe_listener()
+	stop := serve(t, &Server{}, l)
+
+	first, err := l.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, first, PROMPT)
+	expect(t, second, PROMPT)
+
+	send(t, first, ":trace\n")
+	expect(t, first, "parser tracing on\n"+PROMPT)
+
+	send(t, second, "let x = 1 +\n")
+	expect(t, second, CONTINUATION_PROMPT)
+	send(t, second, "2\n")
+	if got := expect(t, second, PROMPT); got != "let x = (1 + 2);\n"+PROMPT {
+		t.Errorf("second session was traced or lost its entry. got=%q", got)
+	}
+
+	send(t, first, "1\n")
+	if got := expect(t, first, PROMPT); !strings.Contains(got, "BEGIN parse_expression") {
+		t.Errorf("first session was not traced. got=%q", got)
+	}
+
+	first.Close()
+	second.Close()
+	if err := stop(); err != nil {
+		t.Errorf("Serve failed: %v", err)
+	}
+}
+
+func TestNoFilesOverTheNetwork(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x")
+	if err := os.WriteFile(path, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l := new_pipe_listener()
+	stop := serve(t, &Server{}, l)
+
+	conn, err := l.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, conn, PROMPT)
+
+	send(t, conn, "let x = 1\n")
+	expect(t, conn, PROMPT)
+	send(t, conn, ":save "+path+"\n")
+	expect(t, conn, ":save is not available over the network\n"+PROMPT)
+	send(t, conn, ":load "+path+"\n")
+	if got := expect(t, conn, PROMPT); strings.Contains(got, "secret") ||
+		got != ":load is not available over the network\n"+PROMPT {
+		t.Errorf(":load answered %q", got)
+	}
+
+	if source, _ := os.ReadFile(path); string(source) != "secret" {
+		t.Errorf(":save wrote %q", source)
+	}
+
+	conn.Close()
+	if err := stop(); err != nil {
+		t.Errorf("Serve failed: %v", err)
+	}
+}
+
+func TestConnectionLimit(t *testing.T) {
+	l := new_pipe_listener()
+	stop := serve(t, &Server{Max_connections: 1}, l)
+
+	first, _ := l.dial()
+	expect(t, first, PROMPT)
+
+	second, _ := l.dial()
+	expect(t, second, "too many connections, try again later\n")
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("rejected connection was not closed. got=%v", err)
+	}
+
+	first.Close()
+	// The slot is free once the first session is over.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		third, _ := l.dial()
+		third.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, len(PROMPT))
+		n, _ := io.ReadFull(third, buf)
+		third.Close()
+		if string(buf[:n]) == PROMPT {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("connection slot was not freed")
+		}
+	}
+
+	stop()
+}
+
+func TestIdleTimeout(t *testing.T) {
+	l := new_pipe_listener()
+	stop := serve(t, &Server{Idle_timeout: 50 * time.Millisecond}, l)
+
+	conn, _ := l.dial()
+	expect(t, conn, PROMPT)
+	send(t, conn, "1\n")
+	expect(t, conn, "1\n"+PROMPT)
+
+	expect(t, conn, "\nidle timeout, closing session\n")
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("idle connection was not closed. got=%v", err)
+	}
+
+	stop()
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	l := new_pipe_listener()
+	stop := serve(t, &Server{}, l)
+
+	conn, _ := l.dial()
+	expect(t, conn, PROMPT)
+
+	// Serve waits for the session, so read its farewell while it stops.
+	farewell := make(chan string)
+	go func() {
+		got, _ := io.ReadAll(conn)
+		farewell <- string(got)
+	}()
+
+	if err := stop(); err != nil {
+		t.Errorf("Serve failed: %v", err)
+	}
+	if got := <-farewell; got != "\nserver shutting down\n" {
+		t.Errorf("wrong farewell. got=%q", got)
+	}
+	if _, err := l.dial(); err == nil {
+		t.Errorf("listener still accepts connections")
+	}
+}
+
+func TestServeNetworks(t *testing.T) {
+	listeners := map[string]func() (net.Listener, error){
+		"tcp": func() (net.Listener, error) { return net.Listen("tcp", "127.0.0.1:0") },
+		"unix": func() (net.Listener, error) {
+			return net.Listen("unix", filepath.Join(t.TempDir(), "repl.sock"))
+		},
+	}
+
+	for network, listen := range listeners {
+		l, err := listen()
+		if err != nil {
+			t.Logf("%s: cannot listen: %v", network, err)
+			continue
+		}
+		stop := serve(t, &Server{}, l)
+
+		conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+		if err != nil {
+			t.Fatalf("%s: %v", network, err)
+		}
+		expect(t, conn, PROMPT)
+		send(t, conn, "let x = 5\n")
+		expect(t, conn, "let x = 5;\n"+PROMPT)
+
+		if err := stop(); err != nil {
+			t.Errorf("%s: Serve failed: %v", network, err)
+		}
+		expect(t, conn, "\nserver shutting down\n")
+		conn.Close()
+	}
+}
@@ -0,0 +1,3 @@
+module monkey/rpc
+
+go 1.24.1
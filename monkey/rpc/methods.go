@@ -0,0 +1,285 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"monkey/ast"
+	"monkey/astdump"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"slices"
+)
+
+// MAX_SESSIONS is how many sessions a Server keeps. Starting one more
+// forgets the oldest.
+const MAX_SESSIONS = 1000
+
+// The methods are:
+//
+//	parse     the canonical form and S-expression of a program, and its syntax errors
+//	tokenize  the tokens of a program
+//	format    a program formatted as monkey fmt does
+//	enter     a program as the next entry of a session: the names bound so far
+//	reset     forget a session
+//
+// evaluate is not implemented yet, as there is no evaluator: calling it
+// fails with NOT_IMPLEMENTED and a message saying so.
+var methods map[string]func(s *Server, params json.RawMessage) (any, *Error)
+
+func init() {
+	methods = map[string]func(s *Server, params json.RawMessage) (any, *Error){
+		"parse":    (*Server).parse,
+		"tokenize": (*Server).tokenize,
+		"format":   (*Server).format,
+		"enter":    (*Server).enter,
+		"reset":    (*Server).reset,
+		"evaluate": (*Server).evaluate,
+	}
+}
+
+// Source_params are the params of every method: the program and, for
+// enter and reset, the session it belongs to. An enter without a session
+// starts a new one, whose id is in the result; later entries must send
+// it.
+type Source_params struct {
+	Source  string `json:"source"`
+	Session string `json:"session,omitempty"`
+}
+
+// Position is a location in the source. Line and column start at 1, the
+// column and offset count bytes.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Related struct {
+	Span
+	Message string `json:"message"`
+}
+
+type Fix struct {
+	Span
+	Text string `json:"text"`
+}
+
+// Diagnostic is a syntax error, with what diag prints for it.
+type Diagnostic struct {
+	Span
+	Message string    `json:"message"`
+	Summary string    `json:"summary"`
+	Label   string    `json:"label,omitempty"`
+	Related []Related `json:"related,omitempty"`
+	Help    string    `json:"help,omitempty"`
+	Fixes   []Fix     `json:"fixes,omitempty"`
+}
+
+type Parse_result struct {
+	// Program is the canonical form of the program, as the REPL prints it.
+	Program string       `json:"program"`
+	Sexpr   string       `json:"sexpr"`
+	Errors  []Diagnostic `json:"errors"`
+}
+
+type Token struct {
+	Span
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+}
+
+type Tokenize_result struct {
+	Tokens []Token `json:"tokens"`
+}
+
+type Format_result struct {
+	Source string `json:"source"`
+}
+
+type Session_result struct {
+	Session string `json:"session"`
+	// Program is the canonical form of the entry, as the REPL prints it.
+	Program string `json:"program"`
+	// Names are the names the session's entries have bound so far.
+	Names []string `json:"names"`
+}
+
+// session is the state enter keeps between the entries of a client, like
+// a REPL session.
+type session struct {
+	names []string
+}
+
+// parse_source parses source, returning its syntax errors as diagnostics.
+func parse_source(source string) (*ast.Program, []Diagnostic) {
+	file := token.New_file_set().Add_file("", len(source))
+	p := parser.New(lexer.New(source, lexer.With_file(file)))
+	program := p.Parse_program()
+
+	diagnostics := []Diagnostic{}
+	for _, err := range p.Parse_errors() {
+		d := Diagnostic{
+			Span:    span(file, err.Pos, err.End),
+			Message: err.Msg,
+			Summary: err.Summary(),
+			Label:   err.Label,
+			Help:    err.Help,
+		}
+		for _, related := range err.Related {
+			d.Related = append(d.Related, Related{span(file, related.Pos, related.End), related.Msg})
+		}
+		for _, fix := range err.Fixes {
+			d.Fixes = append(d.Fixes, Fix{span(file, fix.Pos, fix.End), fix.Text})
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return program, diagnostics
+}
+
+func span(file *token.File, pos, end token.Pos) Span {
+	if !end.Is_valid() || end < pos {
+		end = pos
+	}
+	return Span{position(file, pos), position(file, end)}
+}
+
+func position(file *token.File, pos token.Pos) Position {
+	p := file.Position(pos)
+	return Position{Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+func syntax_error(diagnostics []Diagnostic) *Error {
+	return &Error{Code: SYNTAX_ERROR, Message: diagnostics[0].Summary, Data: diagnostics}
+}
+
+func (s *Server) parse(params json.RawMessage) (any, *Error) {
+	var p Source_params
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	program, diagnostics := parse_source(p.Source)
+	return &Parse_result{
+		Program: program.String(),
+		Sexpr:   astdump.Sexpr(program),
+		Errors:  diagnostics,
+	}, nil
+}
+
+func (s *Server) tokenize(params json.RawMessage) (any, *Error) {
+	var p Source_params
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	result := &Tokenize_result{Tokens: []Token{}}
+	file := token.New_file_set().Add_file("", len(p.Source))
+	for tok := range lexer.Tokens(p.Source, lexer.With_file(file)) {
+		end := tok.Pos + token.Pos(len(tok.Literal))
+		if tok.Literal == "\n" {
+			// An inserted semicolon takes up no source.
+			end = tok.Pos
+		}
+		result.Tokens = append(result.Tokens, Token{
+			Span:    span(file, tok.Pos, end),
+			Type:    tok.Type.String(),
+			Literal: tok.Literal,
+		})
+	}
+	return result, nil
+}
+
+func (s *Server) format(params json.RawMessage) (any, *Error) {
+	var p Source_params
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	if _, diagnostics := parse_source(p.Source); len(diagnostics) != 0 {
+		return nil, syntax_error(diagnostics)
+	}
+	formatted, err := format.Source(p.Source)
+	if err != nil {
+		return nil, &Error{Code: INTERNAL_ERROR, Message: err.Error()}
+	}
+	return &Format_result{Source: formatted}, nil
+}
+
+// enter parses source as the next entry of a session and records the
+// names it binds. Nothing is evaluated.
+func (s *Server) enter(params json.RawMessage) (any, *Error) {
+	var p Source_params
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	program, diagnostics := parse_source(p.Source)
+	if len(diagnostics) != 0 {
+		return nil, syntax_error(diagnostics)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.Session == "" {
+		p.Session = s.start_session()
+	}
+	sess, ok := s.sessions[p.Session]
+	if !ok {
+		return nil, unknown_session(p.Session)
+	}
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.Let_statement); ok && !slices.Contains(sess.names, let.Name.Value) {
+			sess.names = append(sess.names, let.Name.Value)
+		}
+	}
+	return &Session_result{Session: p.Session, Program: program.String(), Names: slices.Clone(sess.names)}, nil
+}
+
+func (s *Server) evaluate(params json.RawMessage) (any, *Error) {
+	return nil, &Error{Code: NOT_IMPLEMENTED, Message: "evaluate is not implemented: there is no evaluator yet"}
+}
+
+// reset forgets a session.
+func (s *Server) reset(params json.RawMessage) (any, *Error) {
+	var p Source_params
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[p.Session]; !ok {
+		return nil, unknown_session(p.Session)
+	}
+	delete(s.sessions, p.Session)
+	s.order = slices.DeleteFunc(s.order, func(id string) bool { return id == p.Session })
+	return &Session_result{Session: p.Session, Names: []string{}}, nil
+}
+
+// start_session starts a session and returns its id, which is random so
+// that clients of an HTTP server cannot reach each other's sessions. s.mu
+// must be held.
+func (s *Server) start_session() string {
+	if len(s.order) >= MAX_SESSIONS {
+		delete(s.sessions, s.order[0])
+		s.order = s.order[1:]
+	}
+	id := rand.Text()
+	s.sessions[id] = &session{names: []string{}}
+	s.order = append(s.order, id)
+	return id
+}
+
+func unknown_session(id string) *Error {
+	if id == "" {
+		return &Error{Code: INVALID_PARAMS, Message: "no session given"}
+	}
+	return &Error{Code: INVALID_PARAMS, Message: "unknown session " + id}
+}
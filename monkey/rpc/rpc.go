@@ -0,0 +1,225 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MAX_MESSAGE_SIZE is the largest request accepted, in bytes.
+const MAX_MESSAGE_SIZE = 16 << 20
+
+// The error codes of JSON-RPC 2.0, and the server errors: SYNTAX_ERROR,
+// which reports the syntax errors of a program in its data, and
+// NOT_IMPLEMENTED, for methods that exist but cannot do their work yet.
+const (
+	PARSE_ERROR      = -32700
+	INVALID_REQUEST  = -32600
+	METHOD_NOT_FOUND = -32601
+	INVALID_PARAMS   = -32602
+	INTERNAL_ERROR   = -32603
+	SYNTAX_ERROR     = -32000
+	NOT_IMPLEMENTED  = -32001
+)
+
+type Request struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// ID is nil for notifications, which get no response.
+	ID json.RawMessage `json:"id,omitempty"`
+}
+
+type Response struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// Server answers JSON-RPC 2.0 requests for the methods in methods.go. It
+// keeps the state of enter's sessions, so one Server should serve all
+// requests of a client.
+type Server struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	order    []string
+}
+
+func New() *Server {
+	return &Server{sessions: make(map[string]*session)}
+}
+
+// Handle answers a request or a batch of requests. It returns nil when
+// there is nothing to answer, as for notifications.
+func (s *Server) Handle(message []byte) []byte {
+	message = bytes.TrimSpace(message)
+
+	if len(message) > 0 && message[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(message, &batch); err != nil {
+			return encode(error_response(nil, PARSE_ERROR, err.Error()))
+		}
+		if len(batch) == 0 {
+			return encode(error_response(nil, INVALID_REQUEST, "empty batch"))
+		}
+
+		var responses []*Response
+		for _, request := range batch {
+			if response := s.handle(request); response != nil {
+				responses = append(responses, response)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return encode(responses)
+	}
+
+	if response := s.handle(message); response != nil {
+		return encode(response)
+	}
+	return nil
+}
+
+func (s *Server) handle(message []byte) *Response {
+	var request Request
+	if err := json.Unmarshal(message, &request); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return error_response(nil, PARSE_ERROR, err.Error())
+		}
+		return error_response(nil, INVALID_REQUEST, err.Error())
+	}
+	if request.Jsonrpc != "2.0" || request.Method == "" {
+		return error_response(request.ID, INVALID_REQUEST, `expected "jsonrpc": "2.0" and a method`)
+	}
+
+	result, err := s.call(request.Method, request.Params)
+	if request.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return &Response{Jsonrpc: "2.0", Error: err, ID: request.ID}
+	}
+	return &Response{Jsonrpc: "2.0", Result: result, ID: request.ID}
+}
+
+func (s *Server) call(name string, params json.RawMessage) (result any, err *Error) {
+	m, ok := methods[name]
+	if !ok {
+		return nil, &Error{Code: METHOD_NOT_FOUND, Message: "unknown method " + name}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &Error{Code: INTERNAL_ERROR, Message: fmt.Sprint(r)}
+		}
+	}()
+	return m(s, params)
+}
+
+// decode reads params into v, an empty params meaning the zero value.
+func decode(params json.RawMessage, v any) *Error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: INVALID_PARAMS, Message: err.Error()}
+	}
+	return nil
+}
+
+func error_response(id json.RawMessage, code int, msg string) *Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{Jsonrpc: "2.0", Error: &Error{Code: code, Message: msg}, ID: id}
+}
+
+func encode(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(error_response(nil, INTERNAL_ERROR, err.Error()))
+	}
+	return data
+}
+
+// Serve_stream answers newline-delimited requests from r, writing each
+// response on a line of w, until r ends.
+func (s *Server) Serve_stream(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MAX_MESSAGE_SIZE)
+
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		response := s.Handle(scanner.Bytes())
+		if response == nil {
+			continue
+		}
+		if _, err := w.Write(append(response, '\n')); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// The timeouts of the servers New_http_server returns.
+const (
+	HTTP_READ_HEADER_TIMEOUT = 10 * time.Second
+	HTTP_READ_TIMEOUT        = time.Minute
+	HTTP_WRITE_TIMEOUT       = time.Minute
+	HTTP_IDLE_TIMEOUT        = 2 * time.Minute
+)
+
+// New_http_server returns an HTTP server that answers requests to address
+// with s. Its timeouts keep slow or idle clients from holding connections
+// open indefinitely.
+func New_http_server(address string, s *Server) *http.Server {
+	return &http.Server{
+		Addr:              address,
+		Handler:           s,
+		ReadHeaderTimeout: HTTP_READ_HEADER_TIMEOUT,
+		ReadTimeout:       HTTP_READ_TIMEOUT,
+		WriteTimeout:      HTTP_WRITE_TIMEOUT,
+		IdleTimeout:       HTTP_IDLE_TIMEOUT,
+	}
+}
+
+// ServeHTTP answers the request or batch in the body of a POST.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	message, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_MESSAGE_SIZE))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	response := s.Handle(message)
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
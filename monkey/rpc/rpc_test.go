@@ -0,0 +1,265 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// call sends a request to s and decodes the result into result, failing
+// the test on an error response.
+func call(t *testing.T, s *Server, method string, params any, result any) {
+	t.Helper()
+	response := request(t, s, method, params)
+	if response.Error != nil {
+		t.Fatalf("%s failed: %v", method, response.Error)
+	}
+	data, _ := json.Marshal(response.Result)
+	if err := json.Unmarshal(data, result); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func request(t *testing.T, s *Server, method string, params any) Response {
+	t.Helper()
+	message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+
+	var response Response
+	if err := json.Unmarshal(s.Handle(message), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Jsonrpc != "2.0" || string(response.ID) != "1" {
+		t.Errorf("wrong envelope: %+v", response)
+	}
+	return response
+}
+
+func TestParse(t *testing.T) {
+	var result Parse_result
+	call(t, New(), "parse", Source_params{Source: "let x = 1 + 2"}, &result)
+
+	if result.Program != "let x = (1 + 2);" || len(result.Errors) != 0 {
+		t.Errorf("wrong result: %+v", result)
+	}
+	if !strings.HasPrefix(result.Sexpr, "(program\n  (let\n    (ident x)") {
+		t.Errorf("wrong sexpr: %q", result.Sexpr)
+	}
+
+	call(t, New(), "parse", Source_params{Source: "let y = 1\nadd(1, 2"}, &result)
+	if len(result.Errors) != 1 {
+		t.Fatalf("wrong errors: %+v", result.Errors)
+	}
+	d := result.Errors[0]
+	if d.Message != "expected next token to be ), got EOF instead" || d.Summary != "expected `)`, found end of input" {
+		t.Errorf("wrong message: %+v", d)
+	}
+	if d.Start != (Position{Offset: 18, Line: 2, Column: 9}) {
+		t.Errorf("wrong position: %+v", d.Start)
+	}
+	if len(d.Related) != 1 || d.Related[0].Message != "call started here" || d.Related[0].Start.Column != 4 {
+		t.Errorf("wrong related: %+v", d.Related)
+	}
+	if d.Help != "insert `)`" || len(d.Fixes) != 1 || d.Fixes[0].Text != ")" || d.Fixes[0].Start.Offset != 18 {
+		t.Errorf("wrong fix: %q %+v", d.Help, d.Fixes)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	var result Tokenize_result
+	call(t, New(), "tokenize", Source_params{Source: "let x\n!= 10"}, &result)
+
+	expected := []Token{
+		{Span{Position{0, 1, 1}, Position{3, 1, 4}}, "LET", "let"},
+		{Span{Position{4, 1, 5}, Position{5, 1, 6}}, "IDENT", "x"},
+		{Span{Position{5, 1, 6}, Position{5, 1, 6}}, ";", "\n"},
+		{Span{Position{6, 2, 1}, Position{8, 2, 3}}, "!=", "!="},
+		{Span{Position{9, 2, 4}, Position{11, 2, 6}}, "INT", "10"},
+	}
+	if len(result.Tokens) != len(expected) {
+		t.Fatalf("wrong tokens: %+v", result.Tokens)
+	}
+	for i, tok := range expected {
+		if result.Tokens[i] != tok {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, tok, result.Tokens[i])
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	s := New()
+	var result Format_result
+	call(t, s, "format", Source_params{Source: "let f=fn(x){x}// id"}, &result)
+
+	if result.Source != "let f = fn(x) { x } // id\n" {
+		t.Errorf("wrong source: %q", result.Source)
+	}
+
+	response := request(t, s, "format", Source_params{Source: "let = 1"})
+	if response.Error == nil || response.Error.Code != SYNTAX_ERROR || response.Error.Message != "expected an identifier, found `=`" {
+		t.Fatalf("wrong error: %+v", response.Error)
+	}
+	data, _ := json.Marshal(response.Error.Data)
+	var diagnostics []Diagnostic
+	json.Unmarshal(data, &diagnostics)
+	if len(diagnostics) == 0 || diagnostics[0].Start.Column != 5 {
+		t.Errorf("wrong error data: %s", data)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := New()
+	var one, two Session_result
+
+	call(t, s, "enter", Source_params{Source: "let a = 1"}, &one)
+	if one.Session == "" {
+		t.Fatalf("no session id returned: %+v", one)
+	}
+	call(t, s, "enter", Source_params{Source: "let b = a; a + b", Session: one.Session}, &one)
+	if one.Program != "let b = a;(a + b)" || strings.Join(one.Names, " ") != "a b" {
+		t.Errorf("wrong result: %+v", one)
+	}
+
+	call(t, s, "enter", Source_params{Source: "let c = 3"}, &two)
+	if two.Session == one.Session || strings.Join(two.Names, " ") != "c" {
+		t.Errorf("sessions share state: %+v", two)
+	}
+
+	var result Session_result
+	call(t, s, "reset", Source_params{Session: one.Session}, &result)
+	if response := request(t, s, "enter", Source_params{Source: "1", Session: one.Session}); response.Error == nil ||
+		response.Error.Code != INVALID_PARAMS || response.Error.Message != "unknown session "+one.Session {
+		t.Errorf("reset session still usable: %+v", response)
+	}
+	if response := request(t, s, "reset", Source_params{}); response.Error == nil || response.Error.Message != "no session given" {
+		t.Errorf("reset without a session accepted: %+v", response)
+	}
+	if response := request(t, s, "enter", Source_params{Source: "x", Session: "guessed"}); response.Error == nil {
+		t.Errorf("unknown session started")
+	}
+
+	if response := request(t, s, "enter", Source_params{Source: "let", Session: two.Session}); response.Error == nil {
+		t.Errorf("syntax error not reported")
+	}
+}
+
+func TestSessionLimit(t *testing.T) {
+	s := New()
+	var first, result Session_result
+	call(t, s, "enter", Source_params{Source: "let x = 1"}, &first)
+	for range MAX_SESSIONS {
+		call(t, s, "enter", Source_params{Source: "let x = 1"}, &result)
+	}
+	if len(s.sessions) != MAX_SESSIONS || s.sessions[first.Session] != nil {
+		t.Errorf("oldest session kept, %d sessions", len(s.sessions))
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{`{"jsonrpc": "2.0", "id": 1, "method": "eval"}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"unknown method eval"},"id":1}`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "evaluate", "params": {"source": "1"}}`,
+			`{"jsonrpc":"2.0","error":{"code":-32001,"message":"evaluate is not implemented: there is no evaluator yet"},"id":1}`},
+		{`{"jsonrpc": "2.0", "id": "a", "method": "parse", "params": {"source": 1}}`,
+			`"code":-32602`},
+		{`{"jsonrpc": "1.0", "id": 2, "method": "parse"}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"expected \"jsonrpc\": \"2.0\" and a method"},"id":2}`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "parse"`, `"code":-32700`},
+		{`[]`, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`},
+		{`42`, `"code":-32600`},
+		{`{"jsonrpc": "2.0", "id": null, "method": "tokenize"}`, `{"jsonrpc":"2.0","result":{"tokens":[]},"id":null}`},
+		{`{"jsonrpc": "2.0", "method": "parse", "params": {"source": "x"}}`, ``},
+		{`[{"jsonrpc": "2.0", "method": "parse"}, {"jsonrpc": "2.0", "id": 7, "method": "tokenize", "params": {"source": "x"}}, 1]`,
+			`[{"jsonrpc":"2.0","result":{"tokens":[{"start":{"offset":0,"line":1,"column":1},"end":{"offset":1,"line":1,"column":2},"type":"IDENT","literal":"x"}]},"id":7},{"jsonrpc":"2.0","error":{"code":-32600,`},
+	}
+
+	for _, tt := range tests {
+		got := string(New().Handle([]byte(tt.message)))
+		if tt.expected == "" && got != "" || !strings.Contains(got, tt.expected) {
+			t.Errorf("message %s: wrong response.\nexpected=%s\ngot=%s", tt.message, tt.expected, got)
+		}
+	}
+}
+
+func TestServeStream(t *testing.T) {
+	input := `{"jsonrpc": "2.0", "id": 1, "method": "parse", "params": {"source": "a"}}` + "\n\n" +
+		`{"jsonrpc": "2.0", "method": "parse", "params": {"source": "b"}}` + "\n" +
+		`{"jsonrpc": "2.0", "id": 2, "method": "format", "params": {"source": "c"}}` + "\n"
+	var out strings.Builder
+
+	if err := New().Serve_stream(strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"jsonrpc":"2.0","result":{"program":"a","sexpr":"(program\n  (expr\n    (ident a)))\n","errors":[]},"id":1}` + "\n" +
+		`{"jsonrpc":"2.0","result":{"source":"c\n"},"id":2}` + "\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%s\ngot=%s", expected, out.String())
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	server := httptest.NewServer(New())
+	defer server.Close()
+
+	post := func(body string) (*http.Response, string) {
+		response, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		data, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response, string(data)
+	}
+
+	response, body := post(`{"jsonrpc": "2.0", "id": 1, "method": "enter", "params": {"source": "let a = 1"}}`)
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("wrong response: %d %s", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	var first Response
+	json.Unmarshal([]byte(body), &first)
+	session := first.Result.(map[string]any)["session"].(string)
+
+	_, body = post(`{"jsonrpc": "2.0", "id": 1, "method": "enter", "params": {"source": "let b = 1", "session": "` + session + `"}}`)
+	if !strings.Contains(body, `"names":["a","b"]`) {
+		t.Errorf("session state lost between requests: %s", body)
+	}
+	// Another client without the id gets a session of its own.
+	_, body = post(`{"jsonrpc": "2.0", "id": 1, "method": "enter", "params": {"source": "let c = 1"}}`)
+	if !strings.Contains(body, `"names":["c"]`) {
+		t.Errorf("clients share a session: %s", body)
+	}
+
+	if response, _ := post(`{"jsonrpc": "2.0", "method": "parse"}`); response.StatusCode != http.StatusNoContent {
+		t.Errorf("notification answered with %d", response.StatusCode)
+	}
+
+	get, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get.Body.Close()
+	if get.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET answered with %d", get.StatusCode)
+	}
+}
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	s := New()
+	server := New_http_server("localhost:0", s)
+
+	if server.Addr != "localhost:0" || server.Handler != s {
+		t.Errorf("wrong address or handler: %q %v", server.Addr, server.Handler)
+	}
+	if server.ReadHeaderTimeout == 0 || server.ReadTimeout == 0 || server.WriteTimeout == 0 || server.IdleTimeout == 0 {
+		t.Errorf("timeouts not set: %+v", server)
+	}
+}
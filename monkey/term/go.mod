@@ -0,0 +1,3 @@
+module monkey/term
+
+go 1.24.1
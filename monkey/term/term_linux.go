@@ -0,0 +1,57 @@
+//go:build linux
+
+// Package term detects terminals and switches them to raw mode, for the
+// REPL's line editor and for deciding whether to color output.
+package term
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func get_termios(fd int) (*syscall.Termios, error) {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return &termios, nil
+}
+
+func set_termios(fd int, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Is_terminal reports whether fd is a terminal.
+func Is_terminal(fd int) bool {
+	_, err := get_termios(fd)
+	return err == nil
+}
+
+// Make_raw puts the terminal into raw mode, as cfmakeraw does, and returns
+// a function that restores the previous mode.
+func Make_raw(fd int) (func(), error) {
+	old, err := get_termios(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := set_termios(fd, &raw); err != nil {
+		return nil, err
+	}
+	return func() { set_termios(fd, old) }, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package term
+
+import "errors"
+
+// Terminals are only detected on Linux. Elsewhere nothing counts as one,
+// so the REPL reads plain lines and output is not colored.
+
+// Is_terminal reports whether fd is a terminal. It always reports false
+// on this platform.
+func Is_terminal(fd int) bool {
+	return false
+}
+
+func Make_raw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
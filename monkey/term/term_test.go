@@ -0,0 +1,26 @@
+package term
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNotTerminals(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer null.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	for _, f := range []*os.File{null, r, w} {
+		if Is_terminal(int(f.Fd())) {
+			t.Errorf("%s taken for a terminal", f.Name())
+		}
+	}
+}
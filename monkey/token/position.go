@@ -0,0 +1,171 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Pos is a compact source position: the byte offset of a token plus the
+// base of the File it belongs to, so one integer identifies a location
+// across every file of a FileSet. The zero value NoPos means unknown.
+type Pos int
+
+const NoPos Pos = 0
+
+func (p Pos) Is_valid() bool { return p != NoPos }
+
+// Position is a Pos resolved to a human readable location. Line and
+// Column are counted from 1; Column is in bytes.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (pos Position) Is_valid() bool { return pos.Line > 0 }
+
+// String returns "file:line:column", "line:column" for unnamed files, or
+// "-" for an invalid position.
+func (pos Position) String() string {
+	if !pos.Is_valid() {
+		if pos.Filename != "" {
+			return pos.Filename
+		}
+		return "-"
+	}
+	if pos.Filename == "" {
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column)
+}
+
+// File records where the lines of one source file start so that positions
+// inside it can be resolved.
+type File struct {
+	name string
+	base int
+
+	mutex sync.Mutex
+	size  int
+	lines []int
+}
+
+func (f *File) Name() string { return f.name }
+func (f *File) Base() int    { return f.base }
+
+func (f *File) Size() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.size
+}
+
+// Add_line records that a line starts at offset. Offsets must increase;
+// others are ignored.
+func (f *File) Add_line(offset int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if offset > f.lines[len(f.lines)-1] {
+		f.lines = append(f.lines, offset)
+		f.size = max(f.size, offset)
+	}
+}
+
+// Extend grows a file whose size was not known when it was added, as more
+// of it is read.
+func (f *File) Extend(size int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.size = max(f.size, size)
+}
+
+// Pos returns the Pos of the byte at offset.
+func (f *File) Pos(offset int) Pos {
+	return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset of p in f.
+func (f *File) Offset(p Pos) int {
+	return int(p) - f.base
+}
+
+func (f *File) Position(p Pos) Position {
+	if !p.Is_valid() {
+		return Position{}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	offset := int(p) - f.base
+	if offset < 0 {
+		return Position{}
+	}
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     line + 1,
+		Column:   offset - f.lines[line] + 1,
+	}
+}
+
+// FileSet hands out non-overlapping Pos ranges to the files added to it.
+type FileSet struct {
+	mutex sync.RWMutex
+	base  int
+	files []*File
+}
+
+func New_file_set() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// Add_file adds a file of the given size in bytes. A size of -1 means the
+// size is not known yet, as for input that is streamed; such a file must
+// be read to the end before the next file is added.
+func (s *FileSet) Add_file(name string, size int) *File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if n := len(s.files); n > 0 {
+		last := s.files[n-1]
+		s.base = max(s.base, last.base+last.Size()+1)
+	}
+
+	f := &File{name: name, base: s.base, size: max(size, 0), lines: []int{0}}
+	s.files = append(s.files, f)
+	if size >= 0 {
+		s.base += size + 1
+	}
+	return f
+}
+
+// File returns the file containing p, or nil.
+func (s *FileSet) File(p Pos) *File {
+	if !p.Is_valid() {
+		return nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) }) - 1
+	if i < 0 {
+		return nil
+	}
+	f := s.files[i]
+	if int(p) > f.base+f.Size() && i < len(s.files)-1 {
+		return nil
+	}
+	return f
+}
+
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
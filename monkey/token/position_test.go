@@ -0,0 +1,75 @@
+package token
+
+import "testing"
+
+func TestFileSetPositions(t *testing.T) {
+	fset := New_file_set()
+
+	a := fset.Add_file("a.mk", 12)
+	a.Add_line(4)
+	a.Add_line(9)
+
+	b := fset.Add_file("b.mk", 5)
+	b.Add_line(2)
+
+	tests := []struct {
+		pos      Pos
+		expected string
+	}{
+		{a.Pos(0), "a.mk:1:1"},
+		{a.Pos(3), "a.mk:1:4"},
+		{a.Pos(4), "a.mk:2:1"},
+		{a.Pos(10), "a.mk:3:2"},
+		{a.Pos(12), "a.mk:3:4"},
+		{b.Pos(0), "b.mk:1:1"},
+		{b.Pos(4), "b.mk:2:3"},
+		{NoPos, "-"},
+	}
+
+	for _, tt := range tests {
+		if got := fset.Position(tt.pos).String(); got != tt.expected {
+			t.Errorf("Position(%d) wrong. expected=%q, got=%q", tt.pos, tt.expected, got)
+		}
+	}
+
+	if fset.File(b.Pos(1)) != b || fset.File(a.Pos(11)) != a {
+		t.Errorf("File returned the wrong file")
+	}
+	if a.Pos(12) >= b.Pos(0) {
+		t.Errorf("files overlap: a ends at %d, b starts at %d", a.Pos(12), b.Pos(0))
+	}
+}
+
+func TestFileSetUnknownSize(t *testing.T) {
+	fset := New_file_set()
+
+	streamed := fset.Add_file("stdin", -1)
+	streamed.Add_line(3)
+	streamed.Extend(100)
+
+	next := fset.Add_file("next.mk", 1)
+	if next.Base() <= streamed.Base()+100 {
+		t.Errorf("file added after a streamed one overlaps it. base=%d", next.Base())
+	}
+	if got := fset.Position(streamed.Pos(50)).String(); got != "stdin:2:48" {
+		t.Errorf("streamed position wrong. got=%q", got)
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      Position
+		expected string
+	}{
+		{Position{Filename: "x.mk", Line: 3, Column: 9}, "x.mk:3:9"},
+		{Position{Line: 1, Column: 2}, "1:2"},
+		{Position{Filename: "x.mk"}, "x.mk"},
+		{Position{}, "-"},
+	}
+
+	for _, tt := range tests {
+		if tt.pos.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, tt.pos.String())
+		}
+	}
+}
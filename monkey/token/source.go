@@ -0,0 +1,46 @@
+package token
+
+// TokenSource is anything that hands out tokens one at a time, ending with
+// an EOF token that repeats once the source is exhausted.
+type TokenSource interface {
+	NextToken() Token
+}
+
+type slice_source struct {
+	tokens []Token
+	pos    int
+}
+
+// From_slice returns a TokenSource that yields tokens in order and then EOF.
+func From_slice(tokens []Token) TokenSource {
+	return &slice_source{tokens: tokens}
+}
+
+func (s *slice_source) NextToken() Token {
+	if s.pos >= len(s.tokens) {
+		return Token{Type: EOF, Literal: ""}
+	}
+	tok := s.tokens[s.pos]
+	s.pos++
+	return tok
+}
+
+type filter_source struct {
+	src  TokenSource
+	keep func(Token) bool
+}
+
+// Filter returns a TokenSource that yields only the tokens of src for which
+// keep returns true. EOF is always passed through.
+func Filter(src TokenSource, keep func(Token) bool) TokenSource {
+	return &filter_source{src: src, keep: keep}
+}
+
+func (f *filter_source) NextToken() Token {
+	for {
+		tok := f.src.NextToken()
+		if tok.Type == EOF || f.keep(tok) {
+			return tok
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package token
+
+import "testing"
+
+func TestFromSlice(t *testing.T) {
+	src := From_slice([]Token{
+		{Type: IDENT, Literal: "x"},
+		{Type: PLUS, Literal: "+"},
+	})
+
+	expected := []TokenType{IDENT, PLUS, EOF, EOF}
+	for i, tt := range expected {
+		if tok := src.NextToken(); tok.Type != tt {
+			t.Fatalf("tokens[%d] wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	src := Filter(From_slice([]Token{
+		{Type: IDENT, Literal: "x"},
+		{Type: ILLEGAL, Literal: "@"},
+		{Type: ILLEGAL, Literal: "$"},
+		{Type: INT, Literal: "5"},
+	}), func(tok Token) bool { return tok.Type != ILLEGAL })
+
+	expected := []TokenType{IDENT, INT, EOF}
+	for i, tt := range expected {
+		if tok := src.NextToken(); tok.Type != tt {
+			t.Fatalf("tokens[%d] wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
@@ -1,59 +1,146 @@
 package token
 
-type TokenType string
+import (
+	"slices"
+	"strconv"
+	"sync"
+)
+
+type TokenType int
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Pos
 }
 
 const (
-	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF"
+	ILLEGAL TokenType = iota
+	EOF
 	// Identifiers + literals
-	IDENT = "IDENT" // add, foobar, x, y, ...
-	INT   = "INT"   // 1343456
+	IDENT // add, foobar, x, y, ...
+	INT   // 1343456
 	// Operators
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	BANG     = "!"
-	SLASH    = "/"
-	ASTERISK = "*"
-	LT       = "<"
-	GT       = ">"
-	EQ       = "=="
-	NOT_EQ   = "!="
+	ASSIGN
+	PLUS
+	MINUS
+	BANG
+	SLASH
+	ASTERISK
+	LT
+	GT
+	EQ
+	NOT_EQ
 
 	// Delimiters
-	COMMA     = ","
-	SEMICOLON = ";"
-	LPAREN    = "("
-	RPAREN    = ")"
-	LBRACE    = "{"
-	RBRACE    = "}"
+	COMMA
+	SEMICOLON
+	LPAREN
+	RPAREN
+	LBRACE
+	RBRACE
 	// Keywords
-	FUNCTION = "FUNCTION"
-	LET      = "LET"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	RETURN   = "RETURN"
+	FUNCTION
+	LET
+	TRUE
+	FALSE
+	IF
+	ELSE
+	RETURN
+
+	builtin_count
 )
 
-var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+var names = [builtin_count]string{
+	ILLEGAL:   "ILLEGAL",
+	EOF:       "EOF",
+	IDENT:     "IDENT",
+	INT:       "INT",
+	ASSIGN:    "=",
+	PLUS:      "+",
+	MINUS:     "-",
+	BANG:      "!",
+	SLASH:     "/",
+	ASTERISK:  "*",
+	LT:        "<",
+	GT:        ">",
+	EQ:        "==",
+	NOT_EQ:    "!=",
+	COMMA:     ",",
+	SEMICOLON: ";",
+	LPAREN:    "(",
+	RPAREN:    ")",
+	LBRACE:    "{",
+	RBRACE:    "}",
+	FUNCTION:  "FUNCTION",
+	LET:       "LET",
+	TRUE:      "TRUE",
+	FALSE:     "FALSE",
+	IF:        "IF",
+	ELSE:      "ELSE",
+	RETURN:    "RETURN",
+}
+
+var registry struct {
+	sync.RWMutex
+	names []string
+}
+
+func (t TokenType) String() string {
+	if t >= 0 && t < builtin_count {
+		return names[t]
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+	if i := int(t - builtin_count); i >= 0 && i < len(registry.names) {
+		return registry.names[i]
+	}
+	return "TokenType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Register returns a new token type printed as name, for lexer and parser
+// extensions. Registering the same name again returns the same type.
+func Register(name string) TokenType {
+	for t, n := range names {
+		if n == name {
+			return TokenType(t)
+		}
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	for i, n := range registry.names {
+		if n == name {
+			return builtin_count + TokenType(i)
+		}
+	}
+	registry.names = append(registry.names, name)
+	return builtin_count + TokenType(len(registry.names)-1)
+}
+
+var keywords = []string{"fn", "let", "true", "false", "if", "else", "return"}
+
+// Keywords returns the reserved words of the language.
+func Keywords() []string {
+	return slices.Clone(keywords)
 }
 
 func Lookup_identifier(ident string) TokenType {
-	if tok, ok := keywords[ident]; ok {
-		return tok
+	switch ident {
+	case "fn":
+		return FUNCTION
+	case "let":
+		return LET
+	case "true":
+		return TRUE
+	case "false":
+		return FALSE
+	case "if":
+		return IF
+	case "else":
+		return ELSE
+	case "return":
+		return RETURN
 	}
 	return IDENT
 }
@@ -0,0 +1,71 @@
+package token
+
+import "testing"
+
+func TestTokenTypeString(t *testing.T) {
+	tests := []struct {
+		t        TokenType
+		expected string
+	}{
+		{ILLEGAL, "ILLEGAL"},
+		{EOF, "EOF"},
+		{NOT_EQ, "!="},
+		{RBRACE, "}"},
+		{RETURN, "RETURN"},
+		{Register("|>"), "|>"},
+		{TokenType(-1), "TokenType(-1)"},
+	}
+
+	for _, tt := range tests {
+		if tt.t.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, tt.t.String())
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	a := Register("~>")
+	if a < builtin_count {
+		t.Errorf("Register returned a built-in type %d", a)
+	}
+	if Register("~>") != a {
+		t.Errorf("registering the same name twice returned different types")
+	}
+	if Register("<~") == a {
+		t.Errorf("different names registered to the same type")
+	}
+	if Register("==") != EQ {
+		t.Errorf("Register of a built-in name did not return the built-in type")
+	}
+}
+
+func TestLookupIdentifier(t *testing.T) {
+	tests := map[string]TokenType{
+		"fn": FUNCTION, "let": LET, "true": TRUE, "false": FALSE,
+		"if": IF, "else": ELSE, "return": RETURN,
+		"lets": IDENT, "f": IDENT, "Return": IDENT,
+	}
+
+	for ident, expected := range tests {
+		if got := Lookup_identifier(ident); got != expected {
+			t.Errorf("Lookup_identifier(%q) wrong. expected=%s, got=%s", ident, expected, got)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	keywords := Keywords()
+	if len(keywords) != 7 {
+		t.Fatalf("expected 7 keywords, got=%q", keywords)
+	}
+	for _, keyword := range keywords {
+		if Lookup_identifier(keyword) == IDENT {
+			t.Errorf("Keywords() lists %q, which Lookup_identifier does not know", keyword)
+		}
+	}
+
+	keywords[0] = "changed"
+	if Keywords()[0] == "changed" {
+		t.Errorf("Keywords() returned the package's slice")
+	}
+}